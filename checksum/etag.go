@@ -0,0 +1,137 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MiB is the unit S3 clients size multipart upload parts in
+const MiB = 1024 * 1024
+
+// DefaultPartSize is the part size used for multipart uploads when none is given
+const DefaultPartSize = 5 * MiB
+
+// MetadataKey is the user metadata key push stores the hex SHA-256 of each file under,
+// so files can be compared with objects whose ETag is not an MD5, such as SSE-C objects
+const MetadataKey = "sha256"
+
+// FileSHA256 returns the hex SHA-256 digest of a local file
+func FileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FileMatchesETag reports whether the content of a local file matches an S3 ETag.
+// Plain ETags are the MD5 of the content. Multipart ETags ("<md5>-<parts>") are recomputed
+// using partSize and a few common part sizes. ETags in other formats, such as those of
+// SSE-KMS objects, never match.
+func FileMatchesETag(path, etag string, partSize int64) (bool, error) {
+	etag = strings.Trim(etag, `"`)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	digest, parts, multipart := strings.Cut(etag, "-")
+	if !multipart {
+		sum, err := md5Reader(file)
+		if err != nil {
+			return false, err
+		}
+		return sum == digest, nil
+	}
+
+	n, err := strconv.Atoi(parts)
+	if err != nil || n <= 0 {
+		return false, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	for _, size := range candidatePartSizes(info.Size(), n, partSize) {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		sum, err := MultipartETag(file, size)
+		if err != nil {
+			return false, err
+		}
+		if sum == etag {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// MultipartETag computes the ETag S3 assigns to content uploaded in parts of partSize
+func MultipartETag(r io.Reader, partSize int64) (string, error) {
+	var digests []byte
+	parts := 0
+	for {
+		h := md5.New()
+		n, err := io.CopyN(h, r, partSize)
+		if n > 0 {
+			digests = h.Sum(digests)
+			parts++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sum := md5.Sum(digests)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), parts), nil
+}
+
+// candidatePartSizes lists the part sizes that could have split size bytes into n parts
+func candidatePartSizes(size int64, n int, preferred int64) []int64 {
+	// The smallest whole-MiB part size that gives n parts is what most clients pick
+	guess := (size + int64(n) - 1) / int64(n)
+	guess = (guess + MiB - 1) / MiB * MiB
+
+	var sizes []int64
+	seen := make(map[int64]bool)
+	for _, s := range []int64{preferred, guess, 8 * MiB, 16 * MiB, DefaultPartSize} {
+		if s <= 0 || seen[s] {
+			continue
+		}
+		seen[s] = true
+		if (size+s-1)/s == int64(n) {
+			sizes = append(sizes, s)
+		}
+	}
+	return sizes
+}
+
+// md5Reader returns the hex MD5 digest of everything read from r
+func md5Reader(r io.Reader) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
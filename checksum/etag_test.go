@@ -0,0 +1,128 @@
+package checksum
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// multipartETag computes a multipart ETag the way S3 documents it: the MD5 of the
+// concatenated binary MD5s of the parts, followed by the number of parts
+func multipartETag(content []byte, partSize int) string {
+	var digests []byte
+	parts := 0
+	for start := 0; start < len(content); start += partSize {
+		end := min(start+partSize, len(content))
+		sum := md5.Sum(content[start:end])
+		digests = append(digests, sum[:]...)
+		parts++
+	}
+	sum := md5.Sum(digests)
+	return fmt.Sprintf("%x-%d", sum, parts)
+}
+
+func writeFile(t *testing.T, content []byte) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(p, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestMultipartETag(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	for _, partSize := range []int{1000, 3000, 9999, 10000, 20000} {
+		got, err := MultipartETag(bytes.NewReader(content), int64(partSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := multipartETag(content, partSize); got != want {
+			t.Errorf("part size %d: got %s, want %s", partSize, got, want)
+		}
+	}
+}
+
+func TestCandidatePartSizes(t *testing.T) {
+	tests := []struct {
+		size      int64
+		parts     int
+		preferred int64
+		want      []int64
+	}{
+		// 20 MiB in 4 parts of the preferred 5 MiB
+		{20 * MiB, 4, 5 * MiB, []int64{5 * MiB}},
+		// 100 MiB in 13 parts: 8 MiB, also the smallest whole MiB that gives 13 parts
+		{100 * MiB, 13, 5 * MiB, []int64{8 * MiB}},
+		// 100 MiB in 7 parts: 16 MiB, or the 15 MiB guess
+		{100 * MiB, 7, 5 * MiB, []int64{15 * MiB, 16 * MiB}},
+		// A preferred size of 64 MiB for 200 MiB in 4 parts
+		{200 * MiB, 4, 64 * MiB, []int64{64 * MiB, 50 * MiB}},
+		// No candidate splits 10 MiB into 50 parts
+		{10 * MiB, 50, 5 * MiB, nil},
+	}
+
+	for _, tt := range tests {
+		got := candidatePartSizes(tt.size, tt.parts, tt.preferred)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("candidatePartSizes(%d MiB, %d, %d MiB) = %v, want %v",
+				tt.size/MiB, tt.parts, tt.preferred/MiB, got, tt.want)
+		}
+	}
+}
+
+func TestFileMatchesETag(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), int(12*MiB+5))
+	p := writeFile(t, content)
+	plain := fmt.Sprintf("%x", md5.Sum(content))
+
+	tests := []struct {
+		name string
+		etag string
+		want bool
+	}{
+		{"plain", `"` + plain + `"`, true},
+		{"unquoted", plain, true},
+		{"other content", fmt.Sprintf(`"%x"`, md5.Sum([]byte("y"))), false},
+		{"multipart with the preferred size", multipartETag(content, 5*MiB), true},
+		{"multipart with 8 MiB parts", multipartETag(content, 8*MiB), true},
+		{"multipart with the smallest whole MiB parts", multipartETag(content, 7*MiB), true},
+		{"multipart with an unknown part size", multipartETag(content, 6*MiB), false},
+		{"wrong part count", plain + "-9", false},
+		{"malformed part count", plain + "-x", false},
+		{"SSE-KMS", `"0123456789abcdef0123456789abcdef"`, false},
+	}
+
+	for _, tt := range tests {
+		got, err := FileMatchesETag(p, tt.etag, 5*MiB)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: FileMatchesETag = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := FileMatchesETag(filepath.Join(t.TempDir(), "missing"), plain, 5*MiB); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestFileSHA256(t *testing.T) {
+	content := []byte("hello")
+	got, err := FileSHA256(writeFile(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	if want := hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -3,164 +3,635 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
 
 	"download-file-from-bucket/config"
 	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/encryption"
+	"download-file-from-bucket/glob"
+	"download-file-from-bucket/lockfile"
 	"download-file-from-bucket/providers"
+	"download-file-from-bucket/tracing"
 )
 
 var (
-	providerName  string
-	concurrency   int
-	accessKey     string
-	secretKey     string
-	region        string
-	endpoint      string
-	bucket        string
+	concurrency  int
+	lockedFile   string
+	asOf         string
+	skipArchived bool
+	decompress   bool
+	extract      bool
+	checksums    string
+	subdirs      bool
+
+	rewriteRules    []string
+	flatten         bool
+	pathTemplate    string
+	stripComponents int
+
+	decryptKeyFile string
+	decryptKeyEnv  string
 )
 
 var cloneCmd = &cobra.Command{
-	Use:   "clone <source> <destination>",
+	Use:   "clone <source>... <destination>",
 	Short: "Clone a folder from cloud storage to local directory",
 	Long: `Clone (download) an entire folder from cloud storage to a local directory.
 
+Several sources, from any mix of buckets and providers, can be cloned at once.
+They share one pool of workers and are merged into the destination, or put in
+subdirectories named after their bucket and prefix with --subdirs. A merge that
+would write two objects to the same file fails before anything is downloaded.
+
 Source formats:
   s3://bucket-name/path/to/folder/
   spaces://space-name/path/to/folder/
   https://region.digitaloceanspaces.com/space-name/path/to/folder/
 
+Destination formats:
+  ./local-folder                    directory tree
+  snapshot.tar, .tar.gz, .tgz, .zip single archive file
+  -                                 tar stream on stdout
+
 Examples:
   download-bucket clone s3://my-bucket/data/ ./local-data
   download-bucket clone --provider=digitalocean spaces://my-space/images/ ./images
-  download-bucket clone --provider=aws --region=eu-west-1 s3://eu-bucket/files/ ./files`,
-	Args: cobra.ExactArgs(2),
+  download-bucket clone --provider=aws --region=eu-west-1 s3://eu-bucket/files/ ./files
+  download-bucket clone s3://my-bucket/data/ snapshot.tar.gz
+  download-bucket clone s3://my-bucket/data/ - | ssh remote tar -x
+  download-bucket clone s3://logs-eu/app/ s3://logs-us/app/ ./all-logs
+  download-bucket clone --subdirs s3://bucket-a/data/ spaces://space-b/data/ ./mirror
+  download-bucket clone --locked bucket.lock ./dataset
+  download-bucket clone --as-of 2026-09-01T00:00:00Z s3://my-bucket/data/ ./data-september
+  download-bucket clone --watch --interval 30s s3://my-bucket/incoming/ ./incoming`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if lockedFile != "" {
+			return cobra.RangeArgs(1, 2)(cmd, args)
+		}
+		return cobra.MinimumNArgs(2)(cmd, args)
+	},
 	RunE: runClone,
 }
 
 func init() {
 	rootCmd.AddCommand(cloneCmd)
 
-	cloneCmd.Flags().StringVar(&providerName, "provider", "", "Cloud provider (aws, digitalocean)")
+	addProviderFlags(cloneCmd)
 	cloneCmd.Flags().IntVar(&concurrency, "concurrency", 5, "Number of concurrent downloads")
-	cloneCmd.Flags().StringVar(&accessKey, "access-key", "", "Access key (overrides config)")
-	cloneCmd.Flags().StringVar(&secretKey, "secret-key", "", "Secret key (overrides config)")
-	cloneCmd.Flags().StringVar(&region, "region", "", "Region (overrides config)")
-	cloneCmd.Flags().StringVar(&endpoint, "endpoint", "", "Custom endpoint (overrides config)")
-	cloneCmd.Flags().StringVar(&bucket, "bucket", "", "Bucket name (overrides URL)")
+	cloneCmd.Flags().StringVar(&lockedFile, "locked", "", "Download exactly the objects pinned in this lockfile")
+	cloneCmd.Flags().BoolVar(&skipArchived, "skip-archived", false, "Skip GLACIER and DEEP_ARCHIVE objects that have not been restored")
+	cloneCmd.Flags().BoolVar(&decompress, "decompress", false, "Decompress gzip, zstd, bzip2 and xz objects while downloading")
+	cloneCmd.Flags().BoolVar(&extract, "extract", false, "Unpack .tar, .tar.gz, .tar.zst and .zip objects into a directory named after the key")
+	cloneCmd.Flags().BoolVar(&subdirs, "subdirs", false, "Clone each source into a subdirectory named after its bucket and prefix")
+	cloneCmd.Flags().StringVar(&checksums, "checksums", "", "Write a checksum manifest (sha256 writes SHA256SUMS, md5 writes MD5SUMS)")
+	cloneCmd.Flags().StringVar(&decryptKeyFile, "decrypt-key-file", "", "File holding the AES master key for client-side encrypted objects")
+	cloneCmd.Flags().StringVar(&decryptKeyEnv, "decrypt-key-env", "", "Environment variable holding the base64 AES master key for client-side encrypted objects")
+	cloneCmd.Flags().StringVar(&asOf, "as-of", "", "Download the prefix as it existed at this RFC 3339 time (requires versioning)")
+	addFilterFlags(cloneCmd)
+	addFailureFlags(cloneCmd)
+	addHookFlags(cloneCmd)
+	addDryRunFlag(cloneCmd)
+	cloneCmd.Flags().BoolVar(&watchMode, "watch", false, "Keep running, downloading new and changed objects every --interval")
+	cloneCmd.Flags().DurationVar(&watchInterval, "interval", 30*time.Second, "How often to list the source in --watch mode")
+	cloneCmd.Flags().StringVar(&watchState, "state-file", "", "File recording what --watch has downloaded (default: <destination>.watch.json)")
+	cloneCmd.Flags().StringVar(&watchEvents, "events", "text", "Format of --watch events: text or json")
+	cloneCmd.Flags().StringArrayVar(&rewriteRules, "rewrite", nil, "Rewrite file paths with a rule such as 'dt=(\\d+)/(.*) -> $1/$2' (repeatable, first match wins)")
+	cloneCmd.Flags().BoolVar(&flatten, "flatten", false, "Write all files into the destination root, dropping directories")
+	cloneCmd.Flags().StringVar(&pathTemplate, "template", "", "Build file paths from {path}, {key}, {basename}, {etag}, {yyyy}, {mm} and {dd}")
+	cloneCmd.Flags().IntVar(&stripComponents, "strip-components", 0, "Remove this many leading directories from file paths")
+}
+
+// providerFlags holds the CLI overrides for a provider's configuration
+type providerFlags struct {
+	providerName string
+	accessKey    string
+	secretKey    string
+	region       string
+	endpoint     string
+	bucket       string
+	sseKeyFile   string
+	sseKeyEnv    string
+}
+
+// sourceFlags configures the provider that objects are read from
+var sourceFlags providerFlags
+
+// addProviderFlags registers the flags used to select and configure the source provider
+func addProviderFlags(cmd *cobra.Command) {
+	sourceFlags.register(cmd, "", "")
+}
+
+// register adds the provider flags to cmd, with names and descriptions prefixed
+func (f *providerFlags) register(cmd *cobra.Command, namePrefix, usagePrefix string) {
+	cmd.Flags().StringVar(&f.providerName, namePrefix+"provider", "", usagePrefix+"Cloud provider (aws, digitalocean)")
+	cmd.Flags().StringVar(&f.accessKey, namePrefix+"access-key", "", usagePrefix+"Access key (overrides config)")
+	cmd.Flags().StringVar(&f.secretKey, namePrefix+"secret-key", "", usagePrefix+"Secret key (overrides config)")
+	cmd.Flags().StringVar(&f.region, namePrefix+"region", "", usagePrefix+"Region (overrides config)")
+	cmd.Flags().StringVar(&f.endpoint, namePrefix+"endpoint", "", usagePrefix+"Custom endpoint (overrides config)")
+	cmd.Flags().StringVar(&f.bucket, namePrefix+"bucket", "", usagePrefix+"Bucket name (overrides URL)")
+	cmd.Flags().StringVar(&f.sseKeyFile, namePrefix+"sse-c-key-file", "", usagePrefix+"File holding the SSE-C customer key (overrides config)")
+	cmd.Flags().StringVar(&f.sseKeyEnv, namePrefix+"sse-c-key-env", "", usagePrefix+"Environment variable holding the base64 SSE-C customer key (overrides config)")
 }
 
-func runClone(cmd *cobra.Command, args []string) error {
-	sourceURL := args[0]
-	destDir := args[1]
-	
-	verbose, _ := cmd.Flags().GetBool("verbose")
-	
-	// Parse the source URL
-	parsedSource, err := parseSourceURL(sourceURL)
+func runClone(cmd *cobra.Command, args []string) (err error) {
+	sourceURLs := args[:len(args)-1]
+	destDir := args[len(args)-1]
+
+	// Keep stdout clean when the archive is streamed there
+	var out io.Writer = os.Stdout
+	if destinationFormat(destDir) == formatStream {
+		out = os.Stderr
+	}
+
+	if watchMode {
+		if dryRun {
+			return usageError{fmt.Errorf("--dry-run cannot be combined with --watch")}
+		}
+		if err := validateWatch(len(sourceURLs), destDir); err != nil {
+			return err
+		}
+	}
+
+	// --bucket would silently send every source to the same bucket
+	if sourceFlags.bucket != "" && len(sourceURLs) > 1 {
+		return usageError{fmt.Errorf("--bucket cannot be combined with more than one source URL")}
+	}
+
+	var asOfTime time.Time
+	if asOf != "" {
+		if lockedFile != "" {
+			return usageError{fmt.Errorf("--as-of cannot be combined with --locked")}
+		}
+		if len(sourceURLs) > 1 {
+			return usageError{fmt.Errorf("--as-of supports a single source")}
+		}
+		var err error
+		asOfTime, err = time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			return usageError{fmt.Errorf("invalid --as-of time: %w", err)}
+		}
+	}
+
+	// Load the lockfile, which also determines the source
+	var lock *lockfile.Lockfile
+	if lockedFile != "" {
+		var err error
+		lock, err = lockfile.Load(lockedFile)
+		if err != nil {
+			return err
+		}
+		if len(args) == 2 && args[0] != lock.Source {
+			return usageError{fmt.Errorf("source %s does not match lockfile source %s", args[0], lock.Source)}
+		}
+		sourceURLs = []string{lock.Source}
+	}
+
+	// Parse the source URLs, each with its own provider
+	var parsedSources []*SourceInfo
+	var sources []downloader.Source
+	for _, sourceURL := range sourceURLs {
+		parsedSource, err := parseSourceURL(sourceURL)
+		if err != nil {
+			return fmt.Errorf("invalid source URL %s: %w", sourceURL, err)
+		}
+
+		provider, err := newProvider(parsedSource, &sourceFlags)
+		if err != nil {
+			return err
+		}
+		defer provider.Close()
+
+		source := downloader.Source{
+			Provider: provider,
+			Prefix:   parsedSource.Prefix,
+			Pattern:  parsedSource.Pattern,
+		}
+		if subdirs {
+			source.Dir = parsedSource.dirName()
+		}
+
+		parsedSources = append(parsedSources, parsedSource)
+		sources = append(sources, source)
+	}
+	parsedSource, provider := parsedSources[0], sources[0].Provider
+
+	decrypter, err := newDecrypter()
 	if err != nil {
-		return fmt.Errorf("invalid source URL: %w", err)
+		return err
 	}
 
-	// Load configuration
-	cfg, err := config.LoadConfig()
+	checksumAlgorithm, err := downloader.ParseChecksumAlgorithm(checksums)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return usageError{err}
 	}
 
-	// Determine provider configuration
-	providerConfig, err := getProviderConfig(cfg, parsedSource)
+	filter, err := buildFilter()
 	if err != nil {
-		return fmt.Errorf("failed to get provider config: %w", err)
+		return err
 	}
 
-	// Create provider
-	opts := providers.GetProviderOptions(
-		providerConfig.Type,
-		providerConfig.Region,
-		providerConfig.Endpoint,
-		providerConfig.AccessKey,
-		providerConfig.SecretKey,
-		providerConfig.Bucket,
-		providerConfig.Options,
-	)
+	failures, err := buildFailurePolicy()
+	if err != nil {
+		return err
+	}
 
-	provider, err := providers.NewProvider(opts)
+	paths, err := buildPathMapper()
 	if err != nil {
-		return fmt.Errorf("failed to create provider: %w", err)
+		return err
 	}
-	defer provider.Close()
 
 	// Create downloader
 	dl := downloader.NewDownloader(provider, downloader.Options{
-		Concurrency: concurrency,
-		Verbose:     verbose,
+		Concurrency:     concurrency,
+		SkipArchived:    skipArchived,
+		Decrypter:       decrypter,
+		Decompress:      decompress,
+		Extract:         extract,
+		Checksums:       checksumAlgorithm,
+		Filter:          filter,
+		Failures:        failures,
+		Paths:           paths,
+		OnFile:          perFileHook(out),
+		HookConcurrency: hookConcurrency,
+		Logger:          logger,
 	})
 
-	// Progress callback
-	var lastProgress time.Time
-	progressCallback := func(progress providers.DownloadProgress) {
-		if verbose && time.Since(lastProgress) > time.Second {
-			if progress.Error != nil {
-				fmt.Printf("Error downloading %s: %v\n", progress.Key, progress.Error)
-			} else if progress.Completed {
-				fmt.Printf("Completed: %s (%d bytes)\n", progress.Key, progress.BytesDownloaded)
+	if watchMode {
+		return runWatch(dl, sources[0], destDir, out)
+	}
+
+	// Stop on Ctrl-C or SIGTERM, still reporting what was downloaded
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Trace the whole clone under one root span
+	ctx, span := tracing.Start(ctx, "clone",
+		attribute.StringSlice("sources", sourceURLs),
+		attribute.String("destination", destDir),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	// Refuse to start if the bucket no longer matches the lockfile
+	if lock != nil {
+		if err := lock.Check(ctx, provider, concurrency); err != nil {
+			return err
+		}
+	}
+
+	// Locked and point-in-time clones download a fixed list of objects instead of listing the sources
+	fixed := lock != nil || asOf != ""
+	var objects []providers.Object
+	if lock != nil {
+		objects = lock.Objects()
+	} else if asOf != "" {
+		versions, err := provider.ListObjectVersions(ctx, parsedSource.listPrefix())
+		if err != nil {
+			return err
+		}
+		objects = providers.VersionsAsOf(versions, asOfTime)
+	}
+
+	// A dry run plans the clone without opening the destination
+	if dryRun {
+		sink := planSink(destDir)
+		var plan *downloader.Plan
+		if fixed {
+			plan, err = dl.PlanSourceObjects(ctx, sources[0], objects, sink)
+		} else {
+			plan, err = dl.PlanSources(ctx, sources, sink)
+		}
+		if err != nil {
+			return fmt.Errorf("dry run failed: %w", err)
+		}
+
+		if _, isDir := sink.(*downloader.DirSink); !isDir {
+			if _, statErr := os.Stat(destDir); statErr == nil {
+				fmt.Fprintf(out, "Archive %s exists and would be replaced\n", destDir)
 			}
-			lastProgress = time.Now()
 		}
+		return printPlan(out, plan)
+	}
+
+	sink, closeSink, err := openSink(destDir)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Cloning %s to %s...\n", sourceURL, destDir)
-	
+	fmt.Fprintf(out, "Cloning %s to %s...\n", strings.Join(sourceURLs, ", "), destDir)
+
 	// Start download
-	ctx := context.Background()
-	result, err := dl.DownloadFolder(ctx, parsedSource.Prefix, destDir, progressCallback)
+	var result *downloader.DownloadResult
+	if fixed {
+		result, err = dl.DownloadSourceObjects(ctx, sources[0], objects, sink, nil)
+	} else {
+		result, err = dl.DownloadSources(ctx, sources, sink, nil)
+	}
+	if closeErr := closeSink(); err == nil && closeErr != nil {
+		err = closeErr
+	}
 	if err != nil {
 		return fmt.Errorf("download failed: %w", err)
 	}
 
-	// Print results
-	fmt.Printf("\nDownload completed!\n")
-	fmt.Printf("Files: %d total, %d successful, %d failed\n", 
-		result.TotalFiles, result.SuccessfulFiles, result.FailedFiles)
-	fmt.Printf("Total size: %.2f MB\n", float64(result.TotalBytes)/(1024*1024))
-	fmt.Printf("Duration: %v\n", result.Duration)
+	resultErr := printResult(out, "Download completed!", result)
+	if err := runCompletionHook(ctx, out, destDir, result); err != nil {
+		return err
+	}
+
+	return resultErr
+}
+
+// buildPathMapper converts the path mapping flags into a mapper, or nil if keys are mirrored as they are
+func buildPathMapper() (*downloader.PathMapper, error) {
+	if len(rewriteRules) == 0 && !flatten && pathTemplate == "" && stripComponents == 0 {
+		return nil, nil
+	}
+	if stripComponents < 0 {
+		return nil, usageError{fmt.Errorf("--strip-components must not be negative")}
+	}
 
-	if len(result.Errors) > 0 {
-		fmt.Printf("\nErrors:\n")
-		for _, err := range result.Errors {
-			fmt.Printf("  - %v\n", err)
+	mapper := &downloader.PathMapper{
+		StripComponents: stripComponents,
+		Flatten:         flatten,
+		Template:        pathTemplate,
+	}
+	for _, rule := range rewriteRules {
+		rewrite, err := downloader.ParseRewrite(rule)
+		if err != nil {
+			return nil, usageError{err}
 		}
-		return fmt.Errorf("download completed with %d errors", len(result.Errors))
+		mapper.Rewrites = append(mapper.Rewrites, rewrite)
+	}
+
+	return mapper, nil
+}
+
+// dirName names the subdirectory a source is cloned into with --subdirs
+func (s *SourceInfo) dirName() string {
+	return strings.Trim(s.Bucket+"/"+s.Prefix, "/")
+}
+
+// destFormat is the form a clone destination is written in
+type destFormat int
+
+const (
+	formatDir    destFormat = iota // a directory tree
+	formatStream                   // a tar stream on stdout
+	formatTar
+	formatTarGzip
+	formatZip
+)
+
+// destinationFormat tells the format of a clone destination: "-" for a tar stream on
+// stdout, an archive file chosen by its extension, or else a directory
+func destinationFormat(dest string) destFormat {
+	switch {
+	case dest == "-":
+		return formatStream
+	case strings.HasSuffix(dest, ".tar"):
+		return formatTar
+	case strings.HasSuffix(dest, ".tar.gz"), strings.HasSuffix(dest, ".tgz"):
+		return formatTarGzip
+	case strings.HasSuffix(dest, ".zip"):
+		return formatZip
+	default:
+		return formatDir
+	}
+}
+
+// newArchiveSink returns a sink writing an archive or stream format to w
+func newArchiveSink(format destFormat, w io.Writer) downloader.Sink {
+	switch format {
+	case formatTarGzip:
+		return downloader.NewTarSink(w, true)
+	case formatZip:
+		return downloader.NewZipSink(w)
+	default:
+		return downloader.NewTarSink(w, false)
+	}
+}
+
+// openSink opens the destination of a clone in the format destinationFormat tells
+func openSink(dest string) (downloader.Sink, func() error, error) {
+	format := destinationFormat(dest)
+	switch format {
+	case formatDir:
+		sink := downloader.NewDirSink(dest)
+		return sink, sink.Close, nil
+	case formatStream:
+		sink := newArchiveSink(format, os.Stdout)
+		return sink, sink.Close, nil
+	}
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	sink := newArchiveSink(format, file)
+	closeSink := func() error {
+		if err := sink.Close(); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to finish archive: %w", err)
+		}
+		return file.Close()
+	}
+
+	return sink, closeSink, nil
+}
+
+// printResult prints a summary under the given heading and returns an error if any files failed
+func printResult(out io.Writer, heading string, result *downloader.DownloadResult) error {
+	fmt.Fprintf(out, "\n%s\n", heading)
+	fmt.Fprintf(out, "Files: %d total, %d successful, %d failed\n",
+		result.TotalFiles, result.SuccessfulFiles, result.FailedFiles)
+	if result.SkippedFiles > 0 {
+		fmt.Fprintf(out, "Skipped: %d\n", result.SkippedFiles)
+	}
+	if result.RemainingFiles > 0 {
+		fmt.Fprintf(out, "Not attempted: %d\n", result.RemainingFiles)
+	}
+	fmt.Fprintf(out, "Total size: %.2f MB\n", float64(result.TotalBytes)/(1024*1024))
+	fmt.Fprintf(out, "Duration: %v\n", result.Duration)
+
+	printErrors(out, "Hook errors", result.HookErrors)
+	printErrors(out, "Errors", result.Errors)
+
+	if err := result.Err(); err != nil {
+		return err
+	}
+	if result.FailedHooks > 0 {
+		return fmt.Errorf("download completed with %d failed hooks", result.FailedHooks)
 	}
 
 	return nil
 }
 
+// maxPrintedErrors caps the errors listed in a summary; the rest are only counted
+const maxPrintedErrors = 20
+
+// printErrors lists errors under a heading, up to maxPrintedErrors of them
+func printErrors(out io.Writer, heading string, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "\n%s:\n", heading)
+	for i, err := range errs {
+		if i == maxPrintedErrors {
+			fmt.Fprintf(out, "  ... and %d more\n", len(errs)-i)
+			break
+		}
+		fmt.Fprintf(out, "  - %v\n", err)
+	}
+}
+
+// newDecrypter creates a decrypter for client-side encrypted objects if a master key was given
+func newDecrypter() (*encryption.Decrypter, error) {
+	if decryptKeyFile == "" && decryptKeyEnv == "" {
+		return nil, nil
+	}
+
+	key, err := encryption.LoadKey(decryptKeyFile, decryptKeyEnv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load master key: %w", err)
+	}
+
+	keys, err := encryption.NewMasterKeyProvider(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return encryption.NewDecrypter(keys), nil
+}
+
+// newProvider creates the provider for a source, merging the config file with CLI flags
+func newProvider(source *SourceInfo, flags *providerFlags) (providers.Provider, error) {
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Determine provider configuration
+	providerConfig, err := getProviderConfig(cfg, source, flags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider config: %w", err)
+	}
+	logger.Debug("using provider", "bucket", source.Bucket, "config", providerConfig)
+
+	// Create provider
+	opts := providers.GetProviderOptions(
+		providerConfig.Type,
+		providerConfig.Region,
+		providerConfig.Endpoint,
+		providerConfig.AccessKey,
+		providerConfig.SecretKey,
+		providerConfig.Bucket,
+		providerConfig.Options,
+	)
+	opts.Logger = logger
+
+	opts.SSECustomerKey, err = providerConfig.SSECustomerKey()
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := providers.NewProvider(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider: %w", err)
+	}
+
+	return provider, nil
+}
+
 // SourceInfo holds parsed information from the source URL
 type SourceInfo struct {
 	Provider string
 	Bucket   string
 	Prefix   string
 	Region   string
+	// Pattern matches keys below Prefix when the URL contains wildcards
+	Pattern *glob.Pattern
+}
+
+// matchObjects keeps the objects whose keys below the prefix match the source pattern
+func (s *SourceInfo) matchObjects(objects []providers.Object) []providers.Object {
+	if s.Pattern == nil {
+		return objects
+	}
+
+	var matched []providers.Object
+	for _, obj := range objects {
+		if s.Pattern.Match(strings.TrimPrefix(obj.Key, s.Prefix)) {
+			matched = append(matched, obj)
+		}
+	}
+	return matched
+}
+
+// listPrefix is the longest literal prefix of the source, used to narrow listings
+func (s *SourceInfo) listPrefix() string {
+	return s.Prefix + s.Pattern.LiteralPrefix()
+}
+
+// listSource lists the objects of a source, keeping only those that match its pattern
+func listSource(ctx context.Context, provider providers.Provider, source *SourceInfo) ([]providers.Object, error) {
+	objects, err := provider.ListObjects(ctx, source.listPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	return source.matchObjects(objects), nil
 }
 
 // parseSourceURL parses the source URL and extracts provider, bucket, and prefix
 func parseSourceURL(sourceURL string) (*SourceInfo, error) {
+	var source *SourceInfo
+	var err error
+
 	// Handle different URL formats
 	if strings.HasPrefix(sourceURL, "s3://") {
-		return parseS3URL(sourceURL)
+		source, err = parseS3URL(sourceURL)
 	} else if strings.HasPrefix(sourceURL, "spaces://") {
-		return parseSpacesURL(sourceURL)
+		source, err = parseSpacesURL(sourceURL)
 	} else if strings.Contains(sourceURL, "digitaloceanspaces.com") {
-		return parseDigitalOceanURL(sourceURL)
+		source, err = parseDigitalOceanURL(sourceURL)
+	} else {
+		return nil, usageError{fmt.Errorf("unsupported URL format: %s", sourceURL)}
+	}
+	if err != nil {
+		return nil, usageError{err}
 	}
 
-	return nil, fmt.Errorf("unsupported URL format: %s", sourceURL)
+	// Wildcards are matched client-side, against keys below the literal directory before them
+	dir, pattern := glob.Split(source.Prefix)
+	if pattern != "" {
+		if source.Pattern, err = glob.Compile(pattern); err != nil {
+			return nil, usageError{err}
+		}
+		source.Prefix = dir
+	}
+
+	return source, nil
+}
+
+// urlPath returns the path of a bucket URL without its leading slash.
+// A "?" is a wildcard in a path, so anything parsed as a query is put back.
+func urlPath(u *url.URL) string {
+	path := strings.TrimPrefix(u.Path, "/")
+	if u.RawQuery != "" || u.ForceQuery {
+		path += "?" + u.RawQuery
+	}
+	return path
 }
 
 func parseS3URL(sourceURL string) (*SourceInfo, error) {
@@ -173,7 +644,7 @@ func parseS3URL(sourceURL string) (*SourceInfo, error) {
 	return &SourceInfo{
 		Provider: "s3",
 		Bucket:   u.Host,
-		Prefix:   strings.TrimPrefix(u.Path, "/"),
+		Prefix:   urlPath(u),
 	}, nil
 }
 
@@ -187,7 +658,7 @@ func parseSpacesURL(sourceURL string) (*SourceInfo, error) {
 	return &SourceInfo{
 		Provider: "digitalocean",
 		Bucket:   u.Host,
-		Prefix:   strings.TrimPrefix(u.Path, "/"),
+		Prefix:   urlPath(u),
 	}, nil
 }
 
@@ -205,7 +676,7 @@ func parseDigitalOceanURL(sourceURL string) (*SourceInfo, error) {
 	}
 
 	region := parts[0]
-	pathParts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	pathParts := strings.SplitN(urlPath(u), "/", 2)
 	if len(pathParts) < 1 {
 		return nil, fmt.Errorf("bucket name not found in URL")
 	}
@@ -225,12 +696,12 @@ func parseDigitalOceanURL(sourceURL string) (*SourceInfo, error) {
 }
 
 // getProviderConfig gets the provider configuration, merging CLI flags with config file
-func getProviderConfig(cfg *config.Config, source *SourceInfo) (*config.ProviderConfig, error) {
+func getProviderConfig(cfg *config.Config, source *SourceInfo, flags *providerFlags) (*config.ProviderConfig, error) {
 	var providerConfig config.ProviderConfig
 
 	// Start with config from file if available
-	if providerName != "" {
-		if pc, exists := cfg.Providers[providerName]; exists {
+	if flags.providerName != "" {
+		if pc, exists := cfg.Providers[flags.providerName]; exists {
 			providerConfig = pc
 		}
 	} else if source.Provider != "" {
@@ -248,25 +719,32 @@ func getProviderConfig(cfg *config.Config, source *SourceInfo) (*config.Provider
 		if source.Provider != "" {
 			providerConfig.Type = source.Provider
 		} else {
-			return nil, fmt.Errorf("provider type not specified")
+			return nil, usageError{fmt.Errorf("provider type not specified")}
 		}
 	}
 
 	// Override with CLI flags
-	if accessKey != "" {
-		providerConfig.AccessKey = accessKey
+	if flags.accessKey != "" {
+		providerConfig.AccessKey = flags.accessKey
+	}
+	if flags.secretKey != "" {
+		providerConfig.SecretKey = flags.secretKey
 	}
-	if secretKey != "" {
-		providerConfig.SecretKey = secretKey
+	if flags.region != "" {
+		providerConfig.Region = flags.region
 	}
-	if region != "" {
-		providerConfig.Region = region
+	if flags.endpoint != "" {
+		providerConfig.Endpoint = flags.endpoint
 	}
-	if endpoint != "" {
-		providerConfig.Endpoint = endpoint
+	if flags.sseKeyFile != "" {
+		providerConfig.SSECustomerKeyFile = flags.sseKeyFile
+		providerConfig.SSECustomerKeyEnv = ""
+	} else if flags.sseKeyEnv != "" {
+		providerConfig.SSECustomerKeyEnv = flags.sseKeyEnv
+		providerConfig.SSECustomerKeyFile = ""
 	}
-	if bucket != "" {
-		providerConfig.Bucket = bucket
+	if flags.bucket != "" {
+		providerConfig.Bucket = flags.bucket
 	} else if source.Bucket != "" {
 		providerConfig.Bucket = source.Bucket
 	}
@@ -285,13 +763,13 @@ func getProviderConfig(cfg *config.Config, source *SourceInfo) (*config.Provider
 
 	// Validate required fields
 	if providerConfig.AccessKey == "" {
-		return nil, fmt.Errorf("access key not provided")
+		return nil, fmt.Errorf("access key not provided: %w", providers.ErrAccessDenied)
 	}
 	if providerConfig.SecretKey == "" {
-		return nil, fmt.Errorf("secret key not provided")
+		return nil, fmt.Errorf("secret key not provided: %w", providers.ErrAccessDenied)
 	}
 	if providerConfig.Bucket == "" {
-		return nil, fmt.Errorf("bucket name not provided")
+		return nil, usageError{fmt.Errorf("bucket name not provided")}
 	}
 
 	return &providerConfig, nil
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestDestinationFormat(t *testing.T) {
+	tests := []struct {
+		dest string
+		want destFormat
+	}{
+		{"-", formatStream},
+		{"backup.tar", formatTar},
+		{"backup.tar.gz", formatTarGzip},
+		{"backup.tgz", formatTarGzip},
+		{"backup.zip", formatZip},
+		{"backup", formatDir},
+		{"./out/", formatDir},
+		{"backup.gz", formatDir},
+	}
+
+	for _, tt := range tests {
+		if got := destinationFormat(tt.dest); got != tt.want {
+			t.Errorf("destinationFormat(%q) = %d, want %d", tt.dest, got, tt.want)
+		}
+	}
+}
+
+func TestPlanSinkMatchesOpenSink(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"backup.tar", "backup.tgz", "backup.zip", "backup"} {
+		dest := filepath.Join(dir, name)
+
+		sink, closeSink, err := openSink(dest)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := closeSink(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if got, want := fmt.Sprintf("%T", planSink(dest)), fmt.Sprintf("%T", sink); got != want {
+			t.Errorf("%s: planned with %s, opened %s", name, got, want)
+		}
+	}
+}
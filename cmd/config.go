@@ -36,12 +36,14 @@ var configListCmd = &cobra.Command{
 }
 
 var (
-	configAccessKey string
-	configSecretKey string
-	configRegion    string
-	configEndpoint  string
-	configBucket    string
-	configType      string
+	configAccessKey  string
+	configSecretKey  string
+	configRegion     string
+	configEndpoint   string
+	configBucket     string
+	configType       string
+	configSSEKeyFile string
+	configSSEKeyEnv  string
 )
 
 func init() {
@@ -55,6 +57,8 @@ func init() {
 	configSetCmd.Flags().StringVar(&configEndpoint, "endpoint", "", "Custom endpoint")
 	configSetCmd.Flags().StringVar(&configBucket, "bucket", "", "Default bucket name")
 	configSetCmd.Flags().StringVar(&configType, "type", "", "Provider type (s3, digitalocean)")
+	configSetCmd.Flags().StringVar(&configSSEKeyFile, "sse-c-key-file", "", "File holding the SSE-C customer key")
+	configSetCmd.Flags().StringVar(&configSSEKeyEnv, "sse-c-key-env", "", "Environment variable holding the base64 SSE-C customer key")
 
 	configSetCmd.MarkFlagRequired("access-key")
 	configSetCmd.MarkFlagRequired("secret-key")
@@ -81,7 +85,7 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 		case "digitalocean", "do", "spaces":
 			providerType = "digitalocean"
 		default:
-			return fmt.Errorf("unknown provider type for %s, please specify with --type", providerName)
+			return usageError{fmt.Errorf("unknown provider type for %s, please specify with --type", providerName)}
 		}
 	}
 
@@ -109,6 +113,14 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 		SecretKey: configSecretKey,
 		Bucket:    configBucket,
 		Options:   make(map[string]string),
+
+		SSECustomerKeyFile: configSSEKeyFile,
+		SSECustomerKeyEnv:  configSSEKeyEnv,
+	}
+
+	// Fail now rather than on the first download if the key cannot be loaded
+	if _, err := providerConfig.SSECustomerKey(); err != nil {
+		return err
 	}
 
 	// Add to config
@@ -150,6 +162,11 @@ func runConfigList(cmd *cobra.Command, args []string) error {
 			fmt.Printf("  Default Bucket: %s\n", provider.Bucket)
 		}
 		fmt.Printf("  Access Key: %s***\n", maskKey(provider.AccessKey))
+		if provider.SSECustomerKeyFile != "" {
+			fmt.Printf("  SSE-C Key File: %s\n", provider.SSECustomerKeyFile)
+		} else if provider.SSECustomerKeyEnv != "" {
+			fmt.Printf("  SSE-C Key Env: $%s\n", provider.SSECustomerKeyEnv)
+		}
 	}
 
 	return nil
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/downloader"
+)
+
+// destFlags configures the provider that objects are written to
+var destFlags providerFlags
+
+var copyCmd = &cobra.Command{
+	Use:   "copy <source> <destination>",
+	Short: "Copy a folder from one bucket to another",
+	Long: `Copy every object under a prefix into another bucket, possibly on another provider
+or in another account. Objects are streamed from source to destination without
+touching local disk, and keep their content type and user metadata.
+
+Source and destination accept the same URL formats as clone. Flags without a
+prefix configure the source; flags starting with --dest- configure the destination.
+
+Examples:
+  download-bucket copy s3://my-bucket/data/ spaces://my-space/data/
+  download-bucket copy --provider=prod --dest-provider=backup s3://prod-bucket/db/ s3://backup-bucket/db/`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCopy,
+}
+
+func init() {
+	rootCmd.AddCommand(copyCmd)
+
+	addProviderFlags(copyCmd)
+	destFlags.register(copyCmd, "dest-", "Destination: ")
+	copyCmd.Flags().IntVar(&concurrency, "concurrency", 5, "Number of concurrent copies")
+	copyCmd.Flags().BoolVar(&skipArchived, "skip-archived", false, "Skip GLACIER and DEEP_ARCHIVE objects that have not been restored")
+	addFilterFlags(copyCmd)
+	addFailureFlags(copyCmd)
+	addDryRunFlag(copyCmd)
+}
+
+func runCopy(cmd *cobra.Command, args []string) error {
+	sourceURL := args[0]
+	destURL := args[1]
+
+	parsedSource, err := parseSourceURL(sourceURL)
+	if err != nil {
+		return fmt.Errorf("invalid source URL: %w", err)
+	}
+	parsedDest, err := parseSourceURL(destURL)
+	if err != nil {
+		return fmt.Errorf("invalid destination URL: %w", err)
+	}
+	if parsedDest.Pattern != nil {
+		return usageError{fmt.Errorf("wildcards are not supported in the destination URL")}
+	}
+
+	source, err := newProvider(parsedSource, &sourceFlags)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	dest, err := newProvider(parsedDest, &destFlags)
+	if err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+	defer dest.Close()
+
+	filter, err := buildFilter()
+	if err != nil {
+		return err
+	}
+
+	failures, err := buildFailurePolicy()
+	if err != nil {
+		return err
+	}
+
+	dl := downloader.NewDownloader(source, downloader.Options{
+		Concurrency:  concurrency,
+		Logger:       logger,
+		SkipArchived: skipArchived,
+		Filter:       filter,
+		Failures:     failures,
+	})
+
+	// Stop on Ctrl-C or SIGTERM; cancelling aborts the multipart uploads in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sink := downloader.NewProviderSink(ctx, dest, parsedDest.Prefix)
+
+	sources := []downloader.Source{{
+		Prefix:  parsedSource.Prefix,
+		Pattern: parsedSource.Pattern,
+	}}
+
+	if dryRun {
+		plan, err := dl.PlanSources(ctx, sources, sink)
+		if err != nil {
+			return fmt.Errorf("dry run failed: %w", err)
+		}
+		return printPlan(os.Stdout, plan)
+	}
+
+	fmt.Printf("Copying %s to %s...\n", sourceURL, destURL)
+
+	result, err := dl.DownloadSources(ctx, sources, sink, nil)
+	if err != nil {
+		return fmt.Errorf("copy failed: %w", err)
+	}
+
+	return printResult(os.Stdout, "Copy completed!", result)
+}
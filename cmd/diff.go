@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/diff"
+	"download-file-from-bucket/providers"
+)
+
+var (
+	diffFormat   string
+	diffChecksum bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <source> <local-dir>",
+	Short: "Compare a bucket folder with a local directory",
+	Long: `Compare the objects under a prefix with the files in a local directory.
+
+Objects missing locally are reported as added, local files missing from the
+bucket as removed, and files that differ as modified. Files are compared by
+size and modification time, or by size and content with --checksum.
+The ETags of SSE-KMS and SSE-C objects are not hashes of their content, so
+with --checksum such files that do not match are reported as unverifiable,
+which is not a difference.
+
+Like diff(1), exits with status 1 when there are differences and 0 when
+there are none. Failures exit with the usual codes, with 2 in place of 1.
+
+Examples:
+  download-bucket diff s3://my-bucket/site/ ./public
+  download-bucket diff --checksum --format=json s3://my-bucket/releases/ ./dist`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	addProviderFlags(diffCmd)
+	diffCmd.Flags().StringVar(&diffFormat, "format", "human", "Output format: human, json or patch-list")
+	diffCmd.Flags().BoolVar(&diffChecksum, "checksum", false, "Compare file content with object ETags instead of modification times")
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	result, err := diffFolder(args)
+	if err != nil {
+		return diffTrouble{err}
+	}
+
+	if n := result.Count(); n > 0 {
+		// Differences are an outcome, not a usage mistake
+		cmd.SilenceUsage = true
+		return differencesError{count: n}
+	}
+
+	return nil
+}
+
+// diffFolder compares the source with the local directory and prints the differences
+func diffFolder(args []string) (*diff.Result, error) {
+	sourceURL := args[0]
+	localDir := args[1]
+
+	switch diffFormat {
+	case "human", "json", "patch-list":
+	default:
+		return nil, usageError{fmt.Errorf("invalid format %q: must be human, json or patch-list", diffFormat)}
+	}
+
+	parsedSource, err := parseSourceURL(sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source URL: %w", err)
+	}
+
+	provider, err := newProvider(parsedSource, &sourceFlags)
+	if err != nil {
+		return nil, err
+	}
+	defer provider.Close()
+
+	ctx := context.Background()
+	objects, err := listSource(ctx, provider, parsedSource)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := diff.CompareObjects(objects, parsedSource.Prefix, localDir, diff.Options{
+		Checksum: diffChecksum,
+		Pattern:  parsedSource.Pattern,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("diff failed: %w", err)
+	}
+
+	// A file cannot be checked against the ETag of an SSE-KMS or SSE-C object
+	if diffChecksum {
+		byKey := make(map[string]providers.Object, len(objects))
+		for _, obj := range objects {
+			byKey[obj.Key] = obj
+		}
+		if err := splitUnverifiable(ctx, provider, result, byKey); err != nil {
+			return nil, fmt.Errorf("diff failed: %w", err)
+		}
+	}
+
+	if err := printDiff(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// printDiff writes the differences to stdout in the selected format
+func printDiff(result *diff.Result) error {
+	switch diffFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case "patch-list":
+		// One change per line with a git-style status letter
+		for _, change := range result.Changes() {
+			status := map[diff.ChangeType]string{diff.Added: "A", diff.Removed: "D", diff.Modified: "M"}[change.Type]
+			fmt.Printf("%s\t%s\n", status, change.Path)
+		}
+		for _, change := range result.Unverifiable {
+			fmt.Printf("?\t%s\n", change.Path)
+		}
+		return nil
+	}
+
+	for _, change := range result.Unverifiable {
+		fmt.Printf("? %s (ETag is not an MD5 of the content)\n", change.Path)
+	}
+	if result.Count() == 0 {
+		fmt.Println("No differences")
+		return nil
+	}
+
+	for _, change := range result.Changes() {
+		switch change.Type {
+		case diff.Added:
+			fmt.Printf("+ %s\n", change.Path)
+		case diff.Removed:
+			fmt.Printf("- %s\n", change.Path)
+		case diff.Modified:
+			fmt.Printf("~ %s (%s)\n", change.Path, change.Reason)
+		}
+	}
+	fmt.Printf("\n%d added, %d removed, %d modified\n", len(result.Added), len(result.Removed), len(result.Modified))
+
+	return nil
+}
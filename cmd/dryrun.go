@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/uploader"
+)
+
+var dryRun bool
+
+// addDryRunFlag registers the flag that prints a plan instead of transferring anything
+func addDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List, filter and map paths, then print what would be transferred without writing anything")
+}
+
+// planSink returns a sink that names files like the one openSink would open for dest,
+// without creating anything. Only directories are listed for files that would be overwritten.
+func planSink(dest string) downloader.Sink {
+	format := destinationFormat(dest)
+	if format == formatDir {
+		return downloader.NewDirSink(dest)
+	}
+	return newArchiveSink(format, io.Discard)
+}
+
+// printPlan lists what a download would do with every object, followed by renamed files,
+// conflicts and totals. It returns an error if some objects could not be planned.
+func printPlan(out io.Writer, plan *downloader.Plan) error {
+	for _, file := range plan.Files {
+		switch file.Action {
+		case downloader.ActionSkip:
+			fmt.Fprintf(out, "%-10s %s (%s)\n", file.Action, file.Key, file.Reason)
+		case downloader.ActionOverwrite:
+			fmt.Fprintf(out, "%-10s %s -> %s (%d bytes, replacing %d bytes)\n", file.Action, file.Key, file.Name, file.Size, file.ExistingSize)
+		default:
+			fmt.Fprintf(out, "%-10s %s -> %s (%d bytes)\n", file.Action, file.Key, file.Name, file.Size)
+		}
+	}
+
+	if plan.SanitizedFiles > 0 {
+		fmt.Fprintf(out, "\nRenamed to be safe as file names:\n")
+		for _, file := range plan.Files {
+			if file.Unsanitized != "" {
+				fmt.Fprintf(out, "  %q -> %q\n", file.Unsanitized, file.Name)
+			}
+		}
+	}
+
+	if len(plan.Conflicts) > 0 {
+		fmt.Fprintf(out, "\nConflicts (the real run fails without downloading anything):\n")
+		for _, conflict := range plan.Conflicts {
+			fmt.Fprintf(out, "  %s <- %s\n", conflict.Name, strings.Join(conflict.Keys, ", "))
+		}
+	}
+
+	fmt.Fprintf(out, "\nDry run, nothing was written\n")
+	fmt.Fprintf(out, "Download: %d files, %.2f MB\n", plan.DownloadFiles, float64(plan.DownloadBytes)/(1024*1024))
+	fmt.Fprintf(out, "Overwrite: %d files, %.2f MB\n", plan.OverwriteFiles, float64(plan.OverwriteBytes)/(1024*1024))
+	fmt.Fprintf(out, "Skip: %d files\n", plan.SkippedFiles)
+	if plan.SanitizedFiles > 0 {
+		fmt.Fprintf(out, "Renamed: %d files\n", plan.SanitizedFiles)
+	}
+	if len(plan.Conflicts) > 0 {
+		fmt.Fprintf(out, "Conflicts: %d\n", len(plan.Conflicts))
+	}
+
+	printErrors(out, "Errors", plan.Errors)
+	if len(plan.Errors) > 0 {
+		return fmt.Errorf("could not plan %d objects: %w", len(plan.Errors), downloader.ErrPartialFailure)
+	}
+
+	return nil
+}
+
+// printUploadPlan lists what a push would do with every local file, followed by totals
+func printUploadPlan(out io.Writer, plan *uploader.Plan) error {
+	for _, file := range plan.Files {
+		fmt.Fprintf(out, "%-10s %s -> %s (%d bytes)\n", file.Action, file.Path, file.Key, file.Size)
+	}
+
+	fmt.Fprintf(out, "\nDry run, nothing was uploaded\n")
+	fmt.Fprintf(out, "Upload: %d files, %.2f MB\n", plan.UploadFiles, float64(plan.UploadBytes)/(1024*1024))
+	fmt.Fprintf(out, "Overwrite: %d files, %.2f MB\n", plan.OverwriteFiles, float64(plan.OverwriteBytes)/(1024*1024))
+	fmt.Fprintf(out, "Unchanged: %d files\n", plan.UnchangedFiles)
+
+	printErrors(out, "Errors", plan.Errors)
+	if len(plan.Errors) > 0 {
+		return fmt.Errorf("could not plan %d files: %w", len(plan.Errors), downloader.ErrPartialFailure)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/providers"
+)
+
+// Exit codes, so scripts can tell failures apart without parsing messages
+const (
+	ExitOK                 = 0
+	ExitFailure            = 1 // any failure not listed below
+	ExitUsage              = 2 // invalid arguments, flags or URLs
+	ExitNotFound           = 3 // bucket, object or version does not exist
+	ExitAccessDenied       = 4 // missing or invalid credentials, or no permission
+	ExitThrottled          = 5 // the provider asked to slow down; retry later
+	ExitInvalidState       = 6 // objects cannot be read as they are, e.g. archived and not restored
+	ExitNetwork            = 7 // the provider could not be reached; retry later
+	ExitPartialFailure     = 8 // some objects failed, or too many did and the rest was not attempted
+	ExitVerificationFailed = 9 // local files do not match the bucket
+	ExitCancelled          = 130
+)
+
+// Exit codes of the diff command, which follows diff(1): 1 means differences were found,
+// so failures that other commands report as ExitFailure exit with ExitDiffTrouble instead
+const (
+	ExitDifferences = 1
+	ExitDiffTrouble = 2
+)
+
+// differencesError is returned by the diff command when the folders differ
+type differencesError struct {
+	count int
+}
+
+func (e differencesError) Error() string { return fmt.Sprintf("found %d differences", e.count) }
+
+// diffTrouble marks errors that kept the diff command from comparing the folders
+type diffTrouble struct {
+	err error
+}
+
+func (e diffTrouble) Error() string { return e.err.Error() }
+func (e diffTrouble) Unwrap() error { return e.err }
+
+// usageError marks errors caused by how the command was invoked. Every check of flags,
+// arguments and URLs returns one, wrapping the parse error if there is one, so
+// invocation mistakes exit with ExitUsage whichever command makes them.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// ExitCode maps the error returned by Execute to the process exit code
+func ExitCode(err error) int {
+	var usage usageError
+	var differences differencesError
+	var trouble diffTrouble
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &differences):
+		return ExitDifferences
+	case errors.As(err, &trouble):
+		if code := ExitCode(trouble.err); code != ExitFailure {
+			return code
+		}
+		return ExitDiffTrouble
+	case errors.Is(err, downloader.ErrCancelled), errors.Is(err, context.Canceled):
+		return ExitCancelled
+	case errors.As(err, &usage):
+		return ExitUsage
+	case errors.Is(err, downloader.ErrVerificationFailed):
+		return ExitVerificationFailed
+	case errors.Is(err, downloader.ErrPartialFailure):
+		return ExitPartialFailure
+	case errors.Is(err, providers.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, providers.ErrAccessDenied):
+		return ExitAccessDenied
+	case errors.Is(err, providers.ErrThrottled):
+		return ExitThrottled
+	case errors.Is(err, providers.ErrInvalidState):
+		return ExitInvalidState
+	case errors.Is(err, providers.ErrNetwork):
+		return ExitNetwork
+	case errors.Is(err, downloader.ErrAborted):
+		return ExitPartialFailure
+	default:
+		return ExitFailure
+	}
+}
+
+// markUsageErrors makes the argument errors of cmd and its subcommands usage errors
+func markUsageErrors(cmd *cobra.Command) {
+	if args := cmd.Args; args != nil {
+		cmd.Args = func(cmd *cobra.Command, a []string) error {
+			if err := args(cmd, a); err != nil {
+				return usageError{err}
+			}
+			return nil
+		}
+	}
+
+	for _, sub := range cmd.Commands() {
+		markUsageErrors(sub)
+	}
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/providers"
+)
+
+func TestExitCode(t *testing.T) {
+	wrap := func(err error) error { return fmt.Errorf("failed to download: %w", err) }
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"other failure", errors.New("boom"), ExitFailure},
+		{"usage", usageError{errors.New("bad flag")}, ExitUsage},
+		{"not found", wrap(providers.ErrNotFound), ExitNotFound},
+		{"access denied", wrap(providers.ErrAccessDenied), ExitAccessDenied},
+		{"throttled", wrap(providers.ErrThrottled), ExitThrottled},
+		{"invalid state", wrap(providers.ErrInvalidState), ExitInvalidState},
+		{"network", wrap(providers.ErrNetwork), ExitNetwork},
+		{"partial failure", wrap(downloader.ErrPartialFailure), ExitPartialFailure},
+		{"aborted", wrap(downloader.ErrAborted), ExitPartialFailure},
+		{"verification failed", wrap(downloader.ErrVerificationFailed), ExitVerificationFailed},
+		{"cancelled", wrap(downloader.ErrCancelled), ExitCancelled},
+		{"context cancelled", wrap(context.Canceled), ExitCancelled},
+		{"cancelled partial failure", fmt.Errorf("%w: %w", downloader.ErrPartialFailure, downloader.ErrCancelled), ExitCancelled},
+
+		{"diff differences", differencesError{count: 3}, ExitDifferences},
+		{"diff other failure", diffTrouble{errors.New("boom")}, ExitDiffTrouble},
+		{"diff usage", diffTrouble{usageError{errors.New("bad flag")}}, ExitUsage},
+		{"diff not found", diffTrouble{wrap(providers.ErrNotFound)}, ExitNotFound},
+		{"diff cancelled", diffTrouble{wrap(context.Canceled)}, ExitCancelled},
+	}
+
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("%s: ExitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+// resetFlags returns the flags of cmd and its subcommands to their defaults, since they
+// are package variables that keep their values from one Execute to the next
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+func TestCommandExitCodes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "dest")
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"unknown flag", []string{"clone", "--no-such-flag", "s3://bucket/", dest}, ExitUsage},
+		{"missing argument", []string{"copy", "s3://bucket/"}, ExitUsage},
+		{"log format", []string{"--log-format=xml", "clone", "s3://bucket/", dest}, ExitUsage},
+		{"unsupported URL", []string{"clone", "ftp://bucket/", dest}, ExitUsage},
+		{"as-of time", []string{"clone", "--as-of", "yesterday", "s3://bucket/", dest}, ExitUsage},
+		{"as-of with locked", []string{"clone", "--as-of", "2024-01-01T00:00:00Z", "--locked", "bucket.lock", dest}, ExitUsage},
+		{"as-of with several sources", []string{"clone", "--as-of", "2024-01-01T00:00:00Z", "s3://a/", "s3://b/", dest}, ExitUsage},
+		{"bucket with several sources", []string{"clone", "--bucket", "other", "s3://a/", "s3://b/", dest}, ExitUsage},
+		{"checksum algorithm", []string{"clone", "--checksums", "crc32", "--access-key=a", "--secret-key=s", "s3://bucket/", dest}, ExitUsage},
+		{"filter", []string{"clone", "--newer-than", "soon", "--access-key=a", "--secret-key=s", "s3://bucket/", dest}, ExitUsage},
+		{"strip components", []string{"clone", "--strip-components=-1", "--access-key=a", "--secret-key=s", "s3://bucket/", dest}, ExitUsage},
+		{"max errors", []string{"clone", "--max-errors=-1", "--access-key=a", "--secret-key=s", "s3://bucket/", dest}, ExitUsage},
+		{"watch interval", []string{"clone", "--watch", "--interval=0", "s3://bucket/", dest}, ExitUsage},
+		{"watch archive", []string{"clone", "--watch", "s3://bucket/", dest + ".zip"}, ExitUsage},
+		{"diff format", []string{"diff", "--format=xml", "s3://bucket/", dir}, ExitUsage},
+		{"diff URL", []string{"diff", "ftp://bucket/", dir}, ExitUsage},
+		{"copy destination wildcard", []string{"copy", "s3://a/", "s3://b/*.txt"}, ExitUsage},
+		{"push part size", []string{"push", "--part-size=1", dir, "s3://bucket/"}, ExitUsage},
+		{"config provider type", []string{"config", "set", "minio", "--access-key=a", "--secret-key=s"}, ExitUsage},
+	}
+
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	defer rootCmd.SetOut(nil)
+	defer rootCmd.SetErr(nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(rootCmd)
+			rootCmd.SetArgs(tt.args)
+			err := Execute()
+			if got := ExitCode(err); got != tt.want {
+				t.Errorf("exit code %d (%v), want %d", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/downloader"
+)
+
+var (
+	failFast     bool
+	maxErrors    int
+	maxErrorRate string
+)
+
+// addFailureFlags registers the flags that abort a download early when objects keep failing
+func addFailureFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&failFast, "fail-fast", false, "Abort on the first error that retrying would not fix, such as access denied")
+	cmd.Flags().IntVar(&maxErrors, "max-errors", 0, "Abort once this many objects have failed (0: no limit)")
+	cmd.Flags().StringVar(&maxErrorRate, "max-error-rate", "", "Abort once more than this share of objects have failed, e.g. 5% (checked after 20 objects)")
+}
+
+// buildFailurePolicy converts the failure flags into a policy for the downloader
+func buildFailurePolicy() (downloader.FailurePolicy, error) {
+	policy := downloader.FailurePolicy{
+		FailFast:  failFast,
+		MaxErrors: maxErrors,
+	}
+	if maxErrors < 0 {
+		return policy, usageError{fmt.Errorf("--max-errors must not be negative")}
+	}
+
+	if maxErrorRate != "" {
+		rate, err := parseRate(maxErrorRate)
+		if err != nil {
+			return policy, usageError{fmt.Errorf("invalid --max-error-rate: %w", err)}
+		}
+		policy.MaxErrorRate = rate
+	}
+
+	return policy, nil
+}
+
+// parseRate parses a percentage such as "5%" or a fraction such as "0.05"
+func parseRate(value string) (float64, error) {
+	s := strings.TrimSpace(value)
+	divisor := 1.0
+	if strings.HasSuffix(s, "%") {
+		s = strings.TrimSuffix(s, "%")
+		divisor = 100
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) || n < 0 || n/divisor > 1 {
+		return 0, fmt.Errorf("%q is not a rate between 0%% and 100%%", value)
+	}
+
+	return n / divisor, nil
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import "testing"
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		ok    bool
+	}{
+		{"5%", 0.05, true},
+		{" 12.5% ", 0.125, true},
+		{"0.05", 0.05, true},
+		{"0", 0, true},
+		{"100%", 1, true},
+		{"1", 1, true},
+		{"101%", 0, false},
+		{"1.5", 0, false},
+		{"-1%", 0, false},
+		{"NaN", 0, false},
+		{"nan%", 0, false},
+		{"Inf", 0, false},
+		{"-Inf%", 0, false},
+		{"+infinity", 0, false},
+		{"five", 0, false},
+		{"%", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRate(tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseRate(%q): err = %v, want ok %v", tt.value, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("parseRate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBuildFailurePolicy(t *testing.T) {
+	defer resetFlags(rootCmd)
+
+	failFast, maxErrors, maxErrorRate = true, 3, "10%"
+	policy, err := buildFailurePolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.FailFast || policy.MaxErrors != 3 || policy.MaxErrorRate != 0.1 {
+		t.Errorf("policy = %+v", policy)
+	}
+
+	for _, set := range []func(){
+		func() { maxErrors, maxErrorRate = -1, "" },
+		func() { maxErrors, maxErrorRate = 0, "NaN" },
+	} {
+		set()
+		if _, err := buildFailurePolicy(); ExitCode(err) != ExitUsage {
+			t.Errorf("max errors %d, rate %q: err = %v, want a usage error", maxErrors, maxErrorRate, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/downloader"
+)
+
+var (
+	newerThan      string
+	olderThan      string
+	minSize        string
+	maxSize        string
+	metadataFilter []string
+	tagFilter      []string
+)
+
+// addFilterFlags registers the flags that select objects by their properties
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&newerThan, "newer-than", "", "Only objects modified after this RFC 3339 time, or within this duration (e.g. 24h, 7d)")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Only objects modified before this RFC 3339 time, or longer ago than this duration")
+	cmd.Flags().StringVar(&minSize, "min-size", "", "Only objects of at least this size (e.g. 512K, 10M, 1G)")
+	cmd.Flags().StringVar(&maxSize, "max-size", "", "Only objects of at most this size (e.g. 512K, 10M, 1G)")
+	cmd.Flags().StringArrayVar(&metadataFilter, "metadata", nil, "Only objects with this user metadata, as key=value (repeatable)")
+	cmd.Flags().StringArrayVar(&tagFilter, "tag", nil, "Only objects with this tag, as key=value (repeatable)")
+}
+
+// buildFilter converts the filter flags into a downloader filter
+func buildFilter() (downloader.Filter, error) {
+	var filter downloader.Filter
+	var err error
+
+	now := time.Now()
+	if newerThan != "" {
+		if filter.NewerThan, err = parseTimeOrAge(newerThan, now); err != nil {
+			return filter, usageError{fmt.Errorf("invalid --newer-than: %w", err)}
+		}
+	}
+	if olderThan != "" {
+		if filter.OlderThan, err = parseTimeOrAge(olderThan, now); err != nil {
+			return filter, usageError{fmt.Errorf("invalid --older-than: %w", err)}
+		}
+	}
+
+	if minSize != "" {
+		if filter.MinSize, err = parseSize(minSize); err != nil {
+			return filter, usageError{fmt.Errorf("invalid --min-size: %w", err)}
+		}
+	}
+	if maxSize != "" {
+		if filter.MaxSize, err = parseSize(maxSize); err != nil {
+			return filter, usageError{fmt.Errorf("invalid --max-size: %w", err)}
+		}
+	}
+
+	if filter.Metadata, err = parseKeyValues(metadataFilter); err != nil {
+		return filter, usageError{fmt.Errorf("invalid --metadata: %w", err)}
+	}
+	if filter.Tags, err = parseKeyValues(tagFilter); err != nil {
+		return filter, usageError{fmt.Errorf("invalid --tag: %w", err)}
+	}
+
+	return filter, nil
+}
+
+// parseTimeOrAge parses an RFC 3339 time, or a duration counted back from now.
+// Durations may use a "d" suffix for days in addition to the units time.ParseDuration accepts.
+func parseTimeOrAge(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", value)
+		}
+		age := n * float64(24*time.Hour)
+		if math.IsNaN(age) || math.Abs(age) >= math.MaxInt64 {
+			return time.Time{}, fmt.Errorf("%q is too long a duration", value)
+		}
+		return now.Add(-time.Duration(age)), nil
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", value)
+	}
+	return now.Add(-age), nil
+}
+
+// parseSize parses a byte count with an optional K, M, G or T suffix, in powers of 1024
+func parseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	multiplier := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			multiplier = int64(1) << (10 * (i + 1))
+			s = s[:len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(n) || n < 0 {
+		return 0, fmt.Errorf("%q is not a size", value)
+	}
+
+	// Converting a float beyond the int64 range would wrap to a negative limit
+	size := n * float64(multiplier)
+	if size >= math.MaxInt64 {
+		return 0, fmt.Errorf("%q is too large a size", value)
+	}
+	return int64(size), nil
+}
+
+// parseKeyValues parses key=value pairs
+func parseKeyValues(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q is not in key=value form", pair)
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
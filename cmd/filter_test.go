@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"1K", 1024},
+		{"1k", 1024},
+		{"1KiB", 1024},
+		{"1.5M", 1536 * 1024},
+		{"10MB", 10 << 20},
+		{"2G", 2 << 30},
+		{"3T", 3 << 40},
+		{" 4K ", 4096},
+		{"8388607T", 8388607 << 40},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "K", "-1", "-1K", "ten", "1X", "NaN", "Inf", "9999999999T", "8388608T", "1e30"} {
+		if got, err := parseSize(value); err == nil {
+			t.Errorf("parseSize(%q) = %d, want an error", value, got)
+		}
+	}
+}
+
+func TestParseTimeOrAge(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2026-10-01T00:00:00Z", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"2026-10-01T02:00:00+02:00", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"24h", now.Add(-24 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"7d", now.Add(-7 * 24 * time.Hour)},
+		{"1.5d", now.Add(-36 * time.Hour)},
+		{"0d", now},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeOrAge(tt.value, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseTimeOrAge(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "2026-10-01", "7 days", "d", "xd", "NaNd", "Infd", "1e10d"} {
+		if got, err := parseTimeOrAge(value, now); err == nil {
+			t.Errorf("parseTimeOrAge(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	got, err := parseKeyValues([]string{"env=prod", "owner=", "note=a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"env": "prod", "owner": "", "note": "a=b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got, err := parseKeyValues(nil); got != nil || err != nil {
+		t.Errorf("no pairs: got %v, %v", got, err)
+	}
+
+	for _, pair := range []string{"env", "=prod"} {
+		if _, err := parseKeyValues([]string{pair}); err == nil {
+			t.Errorf("parseKeyValues(%q): expected an error", pair)
+		}
+	}
+}
+
+func TestBuildFilter(t *testing.T) {
+	defer resetFlags(rootCmd)
+	resetFlags(rootCmd)
+	minSize, maxSize = "1K", "1M"
+	metadataFilter = []string{"owner=ops"}
+	tagFilter = []string{"env=prod", "team=data"}
+
+	filter, err := buildFilter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.MinSize != 1024 || filter.MaxSize != 1<<20 {
+		t.Errorf("sizes %d-%d, want 1024-%d", filter.MinSize, filter.MaxSize, 1<<20)
+	}
+	if !reflect.DeepEqual(filter.Metadata, map[string]string{"owner": "ops"}) {
+		t.Errorf("metadata %v", filter.Metadata)
+	}
+	if !reflect.DeepEqual(filter.Tags, map[string]string{"env": "prod", "team": "data"}) {
+		t.Errorf("tags %v", filter.Tags)
+	}
+
+	tagFilter = []string{"env"}
+	if _, err := buildFilter(); ExitCode(err) != ExitUsage {
+		t.Errorf("invalid --tag: got %v, want a usage error", err)
+	}
+}
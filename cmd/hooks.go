@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/downloader"
+)
+
+var (
+	execPerFile     string
+	execOnComplete  string
+	hookConcurrency int
+)
+
+// addHookFlags registers the flags that run commands after downloads
+func addHookFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&execPerFile, "exec-per-file", "", "Run this shell command for each downloaded file; {path} (empty for archives and streams) and {key} are replaced")
+	cmd.Flags().StringVar(&execOnComplete, "exec-on-complete", "", "Run this shell command once the download has finished")
+	cmd.Flags().IntVar(&hookConcurrency, "hook-concurrency", 2, "Number of --exec-per-file commands that run at once")
+}
+
+// perFileHook returns a hook running --exec-per-file for each file, or nil if it is not set.
+// Command output goes to out. {path} is empty for files written to an archive or a stream,
+// since their names inside the archive are not files a command could open.
+func perFileHook(out io.Writer) downloader.FileHook {
+	if execPerFile == "" {
+		return nil
+	}
+
+	// Placeholders refer to the environment so names are never parsed by the shell
+	command := strings.NewReplacer(
+		"{path}", `"$DOWNLOAD_PATH"`,
+		"{key}", `"$DOWNLOAD_KEY"`,
+	).Replace(execPerFile)
+
+	return func(ctx context.Context, file downloader.DownloadedFile) error {
+		return runHook(ctx, command, out, []string{
+			"DOWNLOAD_PATH=" + file.Path,
+			"DOWNLOAD_NAME=" + file.Name,
+			"DOWNLOAD_KEY=" + file.Key,
+			"DOWNLOAD_VERSION_ID=" + file.VersionID,
+			"DOWNLOAD_SIZE=" + strconv.FormatInt(file.Size, 10),
+			"DOWNLOAD_ETAG=" + strings.Trim(file.ETag, `"`),
+		})
+	}
+}
+
+// runCompletionHook runs --exec-on-complete, if set, with a summary of the result in its environment
+func runCompletionHook(ctx context.Context, out io.Writer, destination string, result *downloader.DownloadResult) error {
+	if execOnComplete == "" {
+		return nil
+	}
+
+	err := runHook(ctx, execOnComplete, out, []string{
+		"DOWNLOAD_DESTINATION=" + destination,
+		"DOWNLOAD_TOTAL_FILES=" + strconv.Itoa(result.TotalFiles),
+		"DOWNLOAD_SUCCESSFUL_FILES=" + strconv.Itoa(result.SuccessfulFiles),
+		"DOWNLOAD_FAILED_FILES=" + strconv.Itoa(result.FailedFiles),
+		"DOWNLOAD_SKIPPED_FILES=" + strconv.Itoa(result.SkippedFiles),
+		"DOWNLOAD_TOTAL_BYTES=" + strconv.FormatInt(result.TotalBytes, 10),
+	})
+	if err != nil {
+		return fmt.Errorf("completion hook failed: %w", err)
+	}
+
+	return nil
+}
+
+// runHook runs a shell command with extra environment variables
+func runHook(ctx context.Context, command string, out io.Writer, env []string) error {
+	c := exec.CommandContext(ctx, "sh", "-c", command)
+	c.Env = append(os.Environ(), env...)
+	c.Stdout = out
+	c.Stderr = os.Stderr
+	return c.Run()
+}
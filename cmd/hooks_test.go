@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"download-file-from-bucket/downloader"
+)
+
+func TestPerFileHookExpandsPlaceholders(t *testing.T) {
+	defer resetFlags(rootCmd)
+
+	if perFileHook(&bytes.Buffer{}) != nil {
+		t.Fatal("perFileHook without --exec-per-file is not nil")
+	}
+
+	execPerFile = `printf '%s|%s|%s\n' {path} {key} "$DOWNLOAD_SIZE"`
+	tests := []struct {
+		name string
+		file downloader.DownloadedFile
+		want string
+	}{
+		{"file on disk", downloader.DownloadedFile{Path: "/tmp/out/a b.txt", Name: "a b.txt", Key: "data/a b.txt", Size: 3}, "/tmp/out/a b.txt|data/a b.txt|3"},
+		{"names are not parsed by the shell", downloader.DownloadedFile{Path: "/tmp/$(id)", Key: "`id`;'", Size: 0}, "/tmp/$(id)|`id`;'|0"},
+		{"file in an archive", downloader.DownloadedFile{Name: "data/a.txt", Key: "data/a.txt", Size: 1}, "|data/a.txt|1"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := perFileHook(&out)(context.Background(), tt.file); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := strings.TrimSuffix(out.String(), "\n"); got != tt.want {
+			t.Errorf("%s: hook printed %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHooksReportFailures(t *testing.T) {
+	defer resetFlags(rootCmd)
+
+	execPerFile = "exit 3"
+	if err := perFileHook(&bytes.Buffer{})(context.Background(), downloader.DownloadedFile{Key: "a.txt"}); err == nil {
+		t.Error("failing per-file hook returned no error")
+	}
+
+	result := &downloader.DownloadResult{TotalFiles: 2, SuccessfulFiles: 1, FailedFiles: 1}
+	if err := runCompletionHook(context.Background(), &bytes.Buffer{}, "dest", result); err != nil {
+		t.Errorf("unset completion hook: %v", err)
+	}
+
+	execOnComplete = `echo "$DOWNLOAD_DESTINATION $DOWNLOAD_SUCCESSFUL_FILES/$DOWNLOAD_TOTAL_FILES"`
+	var out bytes.Buffer
+	if err := runCompletionHook(context.Background(), &out, "dest", result); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "dest 1/2\n" {
+		t.Errorf("completion hook printed %q", got)
+	}
+
+	execOnComplete = "exit 1"
+	err := runCompletionHook(context.Background(), &bytes.Buffer{}, "dest", result)
+	if err == nil || !strings.Contains(err.Error(), "completion hook failed") {
+		t.Errorf("failing completion hook: err = %v", err)
+	}
+}
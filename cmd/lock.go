@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/lockfile"
+	"download-file-from-bucket/providers"
+)
+
+var lockOutput string
+
+var lockCmd = &cobra.Command{
+	Use:   "lock <source>",
+	Short: "Write a lockfile pinning every object under a prefix",
+	Long: `Write a lockfile recording the key, size, ETag and version ID of every object
+under a prefix. The lockfile can later be passed to 'clone --locked' to download
+exactly the same objects.
+
+Examples:
+  download-bucket lock s3://my-bucket/datasets/train/
+  download-bucket lock --output train.lock spaces://my-space/datasets/train/`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLock,
+}
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+
+	addProviderFlags(lockCmd)
+	lockCmd.Flags().StringVarP(&lockOutput, "output", "o", lockfile.DefaultFilename, "Lockfile to write")
+}
+
+func runLock(cmd *cobra.Command, args []string) error {
+	sourceURL := args[0]
+
+	parsedSource, err := parseSourceURL(sourceURL)
+	if err != nil {
+		return fmt.Errorf("invalid source URL: %w", err)
+	}
+
+	provider, err := newProvider(parsedSource, &sourceFlags)
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	ctx := context.Background()
+
+	// List versions rather than objects so version IDs are recorded where versioning is on
+	versions, err := provider.ListObjectVersions(ctx, parsedSource.listPrefix())
+	if err != nil {
+		return err
+	}
+
+	objects := parsedSource.matchObjects(providers.LatestVersions(versions))
+	for _, obj := range objects {
+		logger.Debug("locked", "key", obj.Key, "etag", obj.ETag)
+	}
+
+	lock := lockfile.New(sourceURL, parsedSource.Bucket, parsedSource.Prefix, objects)
+	if err := lock.Save(lockOutput); err != nil {
+		return err
+	}
+
+	fmt.Printf("Locked %d objects from %s\n", len(lock.Entries), sourceURL)
+	fmt.Printf("Lockfile: %s\n", lockOutput)
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	logLevel  string
+	logFormat string
+
+	// logger writes diagnostics to stderr, keeping stdout for command output
+	logger = slog.Default()
+)
+
+// addLoggingFlags registers the global flags that configure logging
+func addLoggingFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warn or error")
+	cmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format: text or json")
+}
+
+// setupLogging creates the logger from --log-level and --log-format.
+// --verbose is shorthand for --log-level=debug.
+func setupLogging(cmd *cobra.Command) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return usageError{fmt.Errorf("invalid --log-level %q: must be debug, info, warn or error", logLevel)}
+	}
+	if verbose, _ := cmd.Flags().GetBool("verbose"); verbose && !cmd.Flags().Changed("log-level") {
+		level = slog.LevelDebug
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch logFormat {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return usageError{fmt.Errorf("invalid --log-format %q: must be text or json", logFormat)}
+	}
+
+	logger = slog.New(handler)
+	slog.SetDefault(logger)
+
+	return nil
+}
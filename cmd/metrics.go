@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/metrics"
+)
+
+var (
+	metricsAddr string
+	metricsFile string
+
+	metricsServer *http.Server
+)
+
+// addMetricsFlags registers the global flags that expose metrics
+func addMetricsFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics on this address at /metrics (e.g. :9090)")
+	cmd.PersistentFlags().StringVar(&metricsFile, "metrics-file", "", "Write Prometheus metrics to this file when the command finishes")
+}
+
+// startMetrics starts the metrics endpoint if --metrics-addr was given
+func startMetrics(cmd *cobra.Command, args []string) error {
+	if metricsAddr == "" {
+		return nil
+	}
+
+	server, err := metrics.Serve(metricsAddr)
+	if err != nil {
+		return err
+	}
+	metricsServer = server
+	logger.Info("serving metrics", "addr", metricsAddr, "path", "/metrics")
+
+	return nil
+}
+
+// finishMetrics writes the --metrics-file dump, whether or not the command succeeded, and stops the endpoint
+func finishMetrics() error {
+	if metricsServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		metricsServer.Shutdown(ctx)
+	}
+
+	if metricsFile == "" {
+		return nil
+	}
+	return metrics.WriteTextfile(metricsFile)
+}
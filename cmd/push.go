@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/checksum"
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/uploader"
+)
+
+var partSizeMB int64
+
+var pushCmd = &cobra.Command{
+	Use:   "push <local-dir> <destination>",
+	Short: "Upload a local directory to a bucket",
+	Long: `Upload every file under a local directory to a prefix in cloud storage.
+Large files are uploaded in parts concurrently. Files whose size and checksum
+already match the object in the bucket are skipped.
+
+The destination accepts the same URL formats as clone.
+
+Examples:
+  download-bucket push ./data s3://my-bucket/data/
+  download-bucket push --part-size=64 ./backups spaces://my-space/backups/`,
+	Args: cobra.ExactArgs(2),
+	RunE: runPush,
+}
+
+func init() {
+	rootCmd.AddCommand(pushCmd)
+
+	addProviderFlags(pushCmd)
+	pushCmd.Flags().IntVar(&concurrency, "concurrency", 5, "Number of concurrent uploads")
+	pushCmd.Flags().Int64Var(&partSizeMB, "part-size", checksum.DefaultPartSize/checksum.MiB, "Multipart upload part size in MiB")
+	addDryRunFlag(pushCmd)
+}
+
+func runPush(cmd *cobra.Command, args []string) error {
+	localDir := args[0]
+	destURL := args[1]
+
+	if partSizeMB < 5 {
+		return usageError{fmt.Errorf("--part-size must be at least 5 MiB")}
+	}
+
+	parsedDest, err := parseSourceURL(destURL)
+	if err != nil {
+		return fmt.Errorf("invalid destination URL: %w", err)
+	}
+	if parsedDest.Pattern != nil {
+		return usageError{fmt.Errorf("wildcards are not supported in the destination URL")}
+	}
+
+	provider, err := newProvider(parsedDest, &sourceFlags)
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	up := uploader.NewUploader(provider, uploader.Options{
+		Concurrency: concurrency,
+		PartSize:    partSizeMB * checksum.MiB,
+		Logger:      logger,
+	})
+
+	if dryRun {
+		plan, err := up.PlanFolder(context.Background(), localDir, parsedDest.Prefix)
+		if err != nil {
+			return fmt.Errorf("dry run failed: %w", err)
+		}
+		return printUploadPlan(os.Stdout, plan)
+	}
+
+	fmt.Printf("Pushing %s to %s...\n", localDir, destURL)
+
+	result, err := up.UploadFolder(context.Background(), localDir, parsedDest.Prefix)
+	if err != nil {
+		return fmt.Errorf("push failed: %w", err)
+	}
+
+	fmt.Printf("\nPush completed!\n")
+	fmt.Printf("Files: %d total, %d uploaded, %d unchanged, %d failed\n",
+		result.TotalFiles, result.UploadedFiles, result.UnchangedFiles, result.FailedFiles)
+	fmt.Printf("Total size: %.2f MB\n", float64(result.TotalBytes)/(1024*1024))
+	fmt.Printf("Duration: %v\n", result.Duration)
+
+	if len(result.Errors) > 0 {
+		printErrors(os.Stdout, "Errors", result.Errors)
+		return fmt.Errorf("push completed with %d errors: %w", len(result.Errors), downloader.ErrPartialFailure)
+	}
+
+	return nil
+}
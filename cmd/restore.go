@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/providers"
+)
+
+var (
+	restoreDays         int64
+	restoreTier         string
+	restoreWait         bool
+	restorePollInterval time.Duration
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <source> [destination]",
+	Short: "Restore archived objects so they can be downloaded",
+	Long: `Request temporary restored copies of every GLACIER and DEEP_ARCHIVE object under
+a prefix. With --wait the command polls until all restored copies are available.
+If a destination is given it also waits, then downloads the restored objects.
+A request that fails, e.g. because the tier is not offered for the storage class,
+is reported and the other objects are still restored.
+
+Examples:
+  download-bucket restore s3://my-bucket/cold-data/
+  download-bucket restore --tier=Bulk --days=3 --wait s3://my-bucket/cold-data/
+  download-bucket restore --poll-interval=15m s3://my-bucket/cold-data/ ./cold-data`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runRestore,
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	addProviderFlags(restoreCmd)
+	restoreCmd.Flags().IntVar(&concurrency, "concurrency", 5, "Number of concurrent downloads")
+	restoreCmd.Flags().Int64Var(&restoreDays, "days", 7, "Number of days to keep the restored copies")
+	restoreCmd.Flags().StringVar(&restoreTier, "tier", "Standard", "Retrieval tier (Expedited, Standard, Bulk)")
+	restoreCmd.Flags().BoolVar(&restoreWait, "wait", false, "Wait until all restored copies are available")
+	restoreCmd.Flags().DurationVar(&restorePollInterval, "poll-interval", 5*time.Minute, "How often to check restore status while waiting")
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	sourceURL := args[0]
+
+	parsedSource, err := parseSourceURL(sourceURL)
+	if err != nil {
+		return fmt.Errorf("invalid source URL: %w", err)
+	}
+
+	provider, err := newProvider(parsedSource, &sourceFlags)
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	// Stop on Ctrl-C or SIGTERM instead of waiting for hours
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	objects, err := listSource(ctx, provider, parsedSource)
+	if err != nil {
+		return err
+	}
+
+	var archived []providers.Object
+	for _, obj := range objects {
+		if obj.IsArchived() {
+			archived = append(archived, obj)
+		}
+	}
+
+	if len(archived) == 0 {
+		fmt.Println("No archived objects found.")
+		return nil
+	}
+
+	restoring, requested, errs := requestRestores(ctx, provider, archived)
+	fmt.Printf("Requested restore of %d objects (%d already restored or in progress, %d failed)\n",
+		requested, len(restoring)-requested, len(errs))
+	printErrors(os.Stdout, "Restore errors", errs)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if (restoreWait || len(args) == 2) && len(restoring) > 0 {
+		if err := waitForRestore(ctx, os.Stdout, provider, restoring); err != nil {
+			return err
+		}
+
+		if len(args) == 2 {
+			destDir := args[1]
+			dl := downloader.NewDownloader(provider, downloader.Options{
+				Concurrency: concurrency,
+				Logger:      logger,
+			})
+
+			fmt.Printf("Downloading %d restored objects to %s...\n", len(restoring), destDir)
+
+			result, err := dl.DownloadObjects(ctx, restoring, parsedSource.Prefix, destDir, nil)
+			if err != nil {
+				return fmt.Errorf("download failed: %w", err)
+			}
+			if err := printResult(os.Stdout, "Download completed!", result); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("could not restore %d of %d objects: %w", len(errs), len(archived), downloader.ErrPartialFailure)
+	}
+	return nil
+}
+
+// requestRestores requests a restore of every archived object that is not already restored or
+// being restored. A failed request, such as a tier the storage class does not offer, is collected
+// and the others carry on. It returns the objects that are restored or being restored.
+func requestRestores(ctx context.Context, provider providers.Provider, archived []providers.Object) ([]providers.Object, int, []error) {
+	var restoring []providers.Object
+	var errs []error
+	requested := 0
+	for _, obj := range archived {
+		if ctx.Err() != nil {
+			break
+		}
+		if obj.Restore != providers.RestoreNone {
+			restoring = append(restoring, obj)
+			continue
+		}
+		if err := provider.RestoreObject(ctx, obj.Key, restoreDays, restoreTier); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		restoring = append(restoring, obj)
+		requested++
+		logger.Debug("restore requested", "key", obj.Key, "storage_class", obj.StorageClass)
+	}
+	return restoring, requested, errs
+}
+
+// waitForRestore polls the given objects until every one has a restored copy available.
+// Throttling and network errors are retried at the next poll.
+func waitForRestore(ctx context.Context, out io.Writer, provider providers.Provider, objects []providers.Object) error {
+	pending := make(map[string]bool)
+	for _, obj := range objects {
+		if obj.Restore != providers.RestoreCompleted {
+			pending[obj.Key] = true
+		}
+	}
+
+	for {
+		for key := range pending {
+			info, err := provider.GetObjectInfo(ctx, key)
+			if err != nil {
+				if ctx.Err() == nil && providers.IsTransient(err) {
+					logger.Warn("checking restore failed, retrying", "key", key, "error", err)
+					continue
+				}
+				return err
+			}
+			if info.Restore == providers.RestoreCompleted {
+				delete(pending, key)
+				logger.Debug("restored", "key", key, "available_until", info.RestoreExpiry)
+			}
+		}
+
+		if len(pending) == 0 {
+			fmt.Fprintln(out, "All objects restored.")
+			return nil
+		}
+
+		fmt.Fprintf(out, "%d of %d objects restored, checking again in %v\n",
+			len(objects)-len(pending), len(objects), restorePollInterval)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(restorePollInterval):
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"download-file-from-bucket/providers"
+)
+
+// archiveProvider accepts restore requests, except for keys in reject, and completes each
+// restore after it has been checked the given number of times
+type archiveProvider struct {
+	providers.Provider
+	reject    map[string]error
+	checks    int
+	requested []string
+	checked   map[string]int
+	flaky     map[string]int
+}
+
+func (p *archiveProvider) RestoreObject(ctx context.Context, key string, days int64, tier string) error {
+	if err := p.reject[key]; err != nil {
+		return fmt.Errorf("failed to restore object %s: %w", key, err)
+	}
+	p.requested = append(p.requested, key)
+	return nil
+}
+
+func (p *archiveProvider) GetObjectInfo(ctx context.Context, key string) (*providers.Object, error) {
+	if p.flaky[key] > 0 {
+		p.flaky[key]--
+		return nil, fmt.Errorf("failed to get %s: %w", key, providers.ErrThrottled)
+	}
+
+	p.checked[key]++
+	status := providers.RestoreInProgress
+	if p.checked[key] >= p.checks {
+		status = providers.RestoreCompleted
+	}
+	return &providers.Object{Key: key, Restore: status}, nil
+}
+
+func archivedObject(key string, restore providers.RestoreStatus) providers.Object {
+	return providers.Object{Key: key, StorageClass: providers.StorageClassDeepArchive, Restore: restore}
+}
+
+func TestRequestRestoresContinuesAfterErrors(t *testing.T) {
+	p := &archiveProvider{reject: map[string]error{"b": providers.ErrInvalidState}}
+	archived := []providers.Object{
+		archivedObject("a", providers.RestoreNone),
+		archivedObject("b", providers.RestoreNone),
+		archivedObject("c", providers.RestoreInProgress),
+		archivedObject("d", providers.RestoreNone),
+	}
+
+	restoring, requested, errs := requestRestores(context.Background(), p, archived)
+
+	var keys []string
+	for _, obj := range restoring {
+		keys = append(keys, obj.Key)
+	}
+	if fmt.Sprint(keys) != "[a c d]" {
+		t.Errorf("restoring %v, want [a c d]", keys)
+	}
+	if requested != 2 || fmt.Sprint(p.requested) != "[a d]" {
+		t.Errorf("requested %d %v, want 2 [a d]", requested, p.requested)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], providers.ErrInvalidState) {
+		t.Errorf("errors %v, want the rejection of b", errs)
+	}
+}
+
+func TestRequestRestoresStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := &archiveProvider{}
+	_, requested, _ := requestRestores(ctx, p, []providers.Object{archivedObject("a", providers.RestoreNone)})
+	if requested != 0 {
+		t.Errorf("requested %d restores after cancelling", requested)
+	}
+}
+
+func TestWaitForRestore(t *testing.T) {
+	defer func(interval time.Duration) { restorePollInterval = interval }(restorePollInterval)
+	restorePollInterval = time.Millisecond
+
+	p := &archiveProvider{
+		checks:  3,
+		checked: make(map[string]int),
+		flaky:   map[string]int{"b": 2},
+	}
+	objects := []providers.Object{
+		archivedObject("a", providers.RestoreInProgress),
+		archivedObject("b", providers.RestoreInProgress),
+		archivedObject("done", providers.RestoreCompleted),
+	}
+
+	if err := waitForRestore(context.Background(), io.Discard, p, objects); err != nil {
+		t.Fatal(err)
+	}
+	if p.checked["a"] != 3 || p.checked["b"] != 3 {
+		t.Errorf("checked %v, want each pending object until it was restored", p.checked)
+	}
+	if p.checked["done"] != 0 {
+		t.Error("checked an object that was already restored")
+	}
+}
+
+func TestWaitForRestoreStopsWhenCancelled(t *testing.T) {
+	defer func(interval time.Duration) { restorePollInterval = interval }(restorePollInterval)
+	restorePollInterval = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	p := &archiveProvider{checks: 2, checked: make(map[string]int)}
+	err := waitForRestore(ctx, io.Discard, p, []providers.Object{archivedObject("a", providers.RestoreInProgress)})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context's error", err)
+	}
+}
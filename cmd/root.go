@@ -18,15 +18,42 @@ Examples:
   download-bucket clone s3://my-bucket/folder/ ./local-folder
   download-bucket clone --provider=digitalocean spaces://my-space/data/ ./data
   download-bucket config set aws --access-key=XXX --secret-key=YYY --region=us-west-2`,
+	PersistentPreRunE: setup,
 }
 
 // Execute executes the root command
 func Execute() error {
-	return rootCmd.Execute()
+	markUsageErrors(rootCmd)
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+
+	err := rootCmd.Execute()
+	if tracingErr := finishTracing(); err == nil {
+		err = tracingErr
+	}
+	if metricsErr := finishMetrics(); err == nil {
+		err = metricsErr
+	}
+	return err
+}
+
+// setup configures logging, the metrics endpoint and tracing before any command runs
+func setup(cmd *cobra.Command, args []string) error {
+	if err := setupLogging(cmd); err != nil {
+		return err
+	}
+	if err := startMetrics(cmd, args); err != nil {
+		return err
+	}
+	return startTracing(cmd.Context())
 }
 
 func init() {
 	// Global flags
-	rootCmd.PersistentFlags().Bool("verbose", false, "Enable verbose output")
+	rootCmd.PersistentFlags().Bool("verbose", false, "Enable verbose output (same as --log-level=debug)")
 	rootCmd.PersistentFlags().String("config", "", "Config file path (default is $HOME/.download-bucket/config.yaml)")
-} 
\ No newline at end of file
+	addLoggingFlags(rootCmd)
+	addMetricsFlags(rootCmd)
+	addTracingFlags(rootCmd)
+}
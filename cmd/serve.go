@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/server"
+)
+
+var (
+	listenAddr     string
+	maxConcurrency int
+	tokenFile      string
+	tokenEnv       string
+	destRoot       string
+	jobRetention   time.Duration
+)
+
+// defaultTokenEnv is the environment variable the API token is read from by default
+const defaultTokenEnv = "DOWNLOAD_BUCKET_TOKEN"
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an HTTP API for submitting and tracking download jobs",
+	Long: `Run a long-lived process that accepts clone jobs over a REST API.
+
+Jobs run concurrently and share one download budget set by --max-concurrency, so
+a busy server never has more than that many downloads in flight. Sources accept
+the same URL formats as clone; a destination is a local directory or archive
+file inside --dest-root, or a bucket URL to copy into. Provider flags apply to
+every source, and --dest- provider flags to every bucket destination.
+
+Every request but /healthz must carry the token read from --token-file or the
+DOWNLOAD_BUCKET_TOKEN environment variable as "Authorization: Bearer <token>".
+Finished jobs are forgotten after --job-retention.
+
+Endpoints:
+  GET    /jobs                 list jobs
+  POST   /jobs                 submit a job
+  GET    /jobs/{id}            show a job and its progress
+  DELETE /jobs/{id}            cancel a job (also POST /jobs/{id}/cancel)
+  GET    /jobs/{id}/events     stream progress as server-sent events
+  GET    /healthz              liveness check
+  GET    /metrics              Prometheus metrics
+
+Examples:
+  DOWNLOAD_BUCKET_TOKEN=secret download-bucket serve --dest-root=/srv/downloads --max-concurrency=32
+  curl -X POST localhost:8080/jobs -H "Authorization: Bearer secret" -H "Content-Type: application/json" \
+    -d '{"sources":["s3://my-bucket/data/"],"destination":"data"}'`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	addProviderFlags(serveCmd)
+	destFlags.register(serveCmd, "dest-", "Destination: ")
+	serveCmd.Flags().StringVar(&listenAddr, "listen", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().IntVar(&maxConcurrency, "max-concurrency", 16, "Maximum concurrent downloads across all jobs")
+	serveCmd.Flags().StringVar(&tokenFile, "token-file", "", "File holding the bearer token that requests must carry")
+	serveCmd.Flags().StringVar(&tokenEnv, "token-env", defaultTokenEnv, "Environment variable holding the bearer token, if --token-file is not given")
+	serveCmd.Flags().StringVar(&destRoot, "dest-root", "", "Directory that local job destinations must be inside; without it only bucket destinations are accepted")
+	serveCmd.Flags().DurationVar(&jobRetention, "job-retention", server.DefaultRetention, "How long finished jobs are kept")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if maxConcurrency <= 0 {
+		return usageError{fmt.Errorf("--max-concurrency must be positive")}
+	}
+	if jobRetention <= 0 {
+		return usageError{fmt.Errorf("--job-retention must be positive")}
+	}
+	token, err := loadToken(tokenFile, tokenEnv)
+	if err != nil {
+		return usageError{err}
+	}
+
+	manager := server.NewManager(openJobSource, openJobSink, server.ManagerOptions{
+		MaxConcurrency: maxConcurrency,
+		Validate:       validateJob,
+		Retention:      jobRetention,
+	})
+	httpServer := &http.Server{
+		Addr:              listenAddr,
+		Handler:           server.NewServer(manager, token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Stop accepting requests on Ctrl-C or SIGTERM, then cancel the running jobs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", listenAddr)
+		errs <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		manager.Close()
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down")
+	manager.Close()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+
+	return nil
+}
+
+// loadToken reads the API token from a file, or from an environment variable if no file is given
+func loadToken(file, env string) (string, error) {
+	var token string
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	} else if env != "" {
+		token = strings.TrimSpace(os.Getenv(env))
+	}
+
+	if token == "" {
+		return "", fmt.Errorf("serve requires an API token: set %s or use --token-file", defaultTokenEnv)
+	}
+	return token, nil
+}
+
+// validateJob checks the URLs of a job request, so malformed requests are rejected instead of failing once the job runs
+func validateJob(req server.JobRequest) error {
+	for _, sourceURL := range req.Sources {
+		if _, err := parseSourceURL(sourceURL); err != nil {
+			return fmt.Errorf("invalid source %s: %w", sourceURL, err)
+		}
+	}
+	_, _, err := jobDestination(req.Destination)
+	return err
+}
+
+// jobDestination resolves a job's destination into either a bucket URL or a local path inside --dest-root
+func jobDestination(dest string) (*SourceInfo, string, error) {
+	if parsedDest, err := parseSourceURL(dest); err == nil {
+		if parsedDest.Pattern != nil {
+			return nil, "", fmt.Errorf("wildcards are not supported in the destination URL")
+		}
+		return parsedDest, "", nil
+	} else if strings.Contains(dest, "://") {
+		return nil, "", fmt.Errorf("invalid destination %s: %w", dest, err)
+	}
+
+	path, err := localJobDestination(dest)
+	if err != nil {
+		return nil, "", err
+	}
+	return nil, path, nil
+}
+
+// localJobDestination resolves a local destination relative to --dest-root and makes sure
+// it stays inside it, including through symlinks
+func localJobDestination(dest string) (string, error) {
+	if destRoot == "" {
+		return "", fmt.Errorf("local destinations are not allowed; start serve with --dest-root to accept them")
+	}
+
+	root, err := filepath.Abs(destRoot)
+	if err != nil {
+		return "", err
+	}
+	path := dest
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid --dest-root: %w", err)
+	}
+	resolved, err := resolveExisting(path)
+	if err != nil {
+		return "", err
+	}
+	if !isWithin(root, path) || !isWithin(resolvedRoot, resolved) {
+		return "", fmt.Errorf("destination %s is outside the destination root", dest)
+	}
+
+	return path, nil
+}
+
+// resolveExisting resolves the symlinks in the longest existing part of path
+func resolveExisting(path string) (string, error) {
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest), nil
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// isWithin reports whether path is dir or below it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// openJobSource resolves a job's source URL using the provider flags
+func openJobSource(sourceURL string) (downloader.Source, error) {
+	parsedSource, err := parseSourceURL(sourceURL)
+	if err != nil {
+		return downloader.Source{}, err
+	}
+
+	provider, err := newProvider(parsedSource, &sourceFlags)
+	if err != nil {
+		return downloader.Source{}, err
+	}
+
+	return downloader.Source{
+		Provider: provider,
+		Prefix:   parsedSource.Prefix,
+		Pattern:  parsedSource.Pattern,
+	}, nil
+}
+
+// openJobSink opens a job's destination: a bucket URL is copied into, a local path is opened like a clone destination
+func openJobSink(ctx context.Context, dest string) (downloader.Sink, func() error, error) {
+	parsedDest, path, err := jobDestination(dest)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parsedDest == nil {
+		return openSink(path)
+	}
+
+	provider, err := newProvider(parsedDest, &destFlags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("destination: %w", err)
+	}
+
+	sink := downloader.NewProviderSink(ctx, provider, parsedDest.Prefix)
+	return sink, provider.Close, nil
+}
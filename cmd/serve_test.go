@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"download-file-from-bucket/server"
+)
+
+func TestJobDestination(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "inside"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "inside"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(old string) { destRoot = old }(destRoot)
+	destRoot = root
+
+	tests := []struct {
+		dest    string
+		want    string
+		wantErr bool
+	}{
+		{dest: "data", want: filepath.Join(root, "data")},
+		{dest: "a/b/snapshot.tar.gz", want: filepath.Join(root, "a/b/snapshot.tar.gz")},
+		{dest: filepath.Join(root, "abs"), want: filepath.Join(root, "abs")},
+		{dest: ".", want: root},
+		{dest: "link/data", want: filepath.Join(root, "link/data")},
+		{dest: "../x", wantErr: true},
+		{dest: "a/../../x", wantErr: true},
+		{dest: "/etc", wantErr: true},
+		{dest: outside, wantErr: true},
+		{dest: "escape/data", wantErr: true},
+		{dest: "escape", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		parsed, path, err := jobDestination(tt.dest)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("jobDestination(%q) = %q, want an error", tt.dest, path)
+			}
+			continue
+		}
+		if err != nil || parsed != nil || path != tt.want {
+			t.Errorf("jobDestination(%q) = %v, %q, %v; want %q", tt.dest, parsed, path, err, tt.want)
+		}
+	}
+
+	parsed, _, err := jobDestination("s3://backup/data/")
+	if err != nil || parsed == nil || parsed.Bucket != "backup" || parsed.Prefix != "data/" {
+		t.Errorf("bucket destination = %+v, %v", parsed, err)
+	}
+	for _, dest := range []string{"s3://backup/data/*.gz", "s3://backup/[a"} {
+		if _, _, err := jobDestination(dest); err == nil {
+			t.Errorf("jobDestination(%q): expected an error", dest)
+		}
+	}
+}
+
+func TestJobDestinationWithoutRoot(t *testing.T) {
+	defer func(old string) { destRoot = old }(destRoot)
+	destRoot = ""
+
+	if _, _, err := jobDestination("data"); err == nil {
+		t.Error("local destinations should be refused without --dest-root")
+	}
+	if _, _, err := jobDestination("s3://backup/data/"); err != nil {
+		t.Errorf("bucket destinations should be accepted without --dest-root: %v", err)
+	}
+}
+
+func TestValidateJob(t *testing.T) {
+	defer func(old string) { destRoot = old }(destRoot)
+	destRoot = t.TempDir()
+
+	tests := []struct {
+		name    string
+		req     server.JobRequest
+		wantErr bool
+	}{
+		{"valid", server.JobRequest{Sources: []string{"s3://bucket/data/", "spaces://space/x/"}, Destination: "data"}, false},
+		{"unsupported source", server.JobRequest{Sources: []string{"ftp://bucket/data/"}, Destination: "data"}, true},
+		{"bad pattern", server.JobRequest{Sources: []string{"s3://bucket/data/[a"}, Destination: "data"}, true},
+		{"escaping destination", server.JobRequest{Sources: []string{"s3://bucket/data/"}, Destination: "../data"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateJob(tt.req); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateJob() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadToken(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SERVE_TOKEN", "from-env")
+	t.Setenv("TEST_EMPTY_TOKEN", "")
+
+	if token, err := loadToken(file, "TEST_SERVE_TOKEN"); err != nil || token != "from-file" {
+		t.Errorf("token file: %q, %v", token, err)
+	}
+	if token, err := loadToken("", "TEST_SERVE_TOKEN"); err != nil || token != "from-env" {
+		t.Errorf("token env: %q, %v", token, err)
+	}
+	if _, err := loadToken("", "TEST_EMPTY_TOKEN"); err == nil {
+		t.Error("an empty token should be refused")
+	}
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/tracing"
+)
+
+var (
+	traceExporter string
+	traceEndpoint string
+
+	shutdownTracing func(context.Context) error
+)
+
+// addTracingFlags registers the global flags that configure tracing
+func addTracingFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&traceExporter, "trace-exporter", "none", "Send OpenTelemetry traces to: none, otlp or stdout")
+	cmd.PersistentFlags().StringVar(&traceEndpoint, "trace-endpoint", "", "OTLP/HTTP collector URL (default from OTEL_EXPORTER_OTLP_ENDPOINT, else http://localhost:4318)")
+}
+
+// startTracing installs the exporter chosen with --trace-exporter
+func startTracing(ctx context.Context) error {
+	exporter, err := tracing.ParseExporter(traceExporter)
+	if err != nil {
+		return usageError{err}
+	}
+
+	shutdownTracing, err = tracing.Setup(ctx, exporter, traceEndpoint)
+	return err
+}
+
+// finishTracing flushes spans that have not been exported yet
+func finishTracing() error {
+	if shutdownTracing == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return shutdownTracing(ctx)
+}
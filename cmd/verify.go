@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"download-file-from-bucket/diff"
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/lockfile"
+	"download-file-from-bucket/providers"
+)
+
+var repair bool
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <source> <local-dir>",
+	Short: "Check a cloned directory for corrupted and missing files",
+	Long: `Re-hash every file in a previously cloned directory and compare it with the
+ETags of the objects in the bucket, or with the objects pinned in a lockfile.
+
+Reports files whose content no longer matches (corrupted), objects with no local
+file (missing) and local files with no object (extra). The ETags of SSE-KMS and
+SSE-C objects are not content hashes, so those files are reported as
+unverifiable instead of corrupted. With --repair, corrupted and missing files
+are downloaded again; extra and unverifiable files are left alone.
+
+Exits with a non-zero status when corrupted or missing files remain.
+
+Examples:
+  download-bucket verify s3://my-bucket/data/ ./local-data
+  download-bucket verify --repair s3://my-bucket/data/ ./local-data
+  download-bucket verify --locked bucket.lock ./dataset`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if lockedFile != "" {
+			return cobra.RangeArgs(1, 2)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	RunE: runVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	addProviderFlags(verifyCmd)
+	verifyCmd.Flags().StringVar(&lockedFile, "locked", "", "Verify against the objects pinned in this lockfile instead of the bucket")
+	verifyCmd.Flags().BoolVar(&repair, "repair", false, "Download corrupted and missing files again")
+	verifyCmd.Flags().IntVar(&concurrency, "concurrency", 5, "Number of concurrent downloads when repairing")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	sourceURL := args[0]
+	localDir := args[len(args)-1]
+
+	// Load the lockfile, which also determines the source
+	var lock *lockfile.Lockfile
+	if lockedFile != "" {
+		var err error
+		lock, err = lockfile.Load(lockedFile)
+		if err != nil {
+			return err
+		}
+		if len(args) == 2 && args[0] != lock.Source {
+			return usageError{fmt.Errorf("source %s does not match lockfile source %s", args[0], lock.Source)}
+		}
+		sourceURL = lock.Source
+	}
+
+	parsedSource, err := parseSourceURL(sourceURL)
+	if err != nil {
+		return fmt.Errorf("invalid source URL: %w", err)
+	}
+
+	provider, err := newProvider(parsedSource, &sourceFlags)
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	ctx := context.Background()
+
+	// The expected state is either what was pinned at clone time or what is in the bucket now
+	var objects []providers.Object
+	if lock != nil {
+		objects = lock.Objects()
+	} else {
+		objects, err = listSource(ctx, provider, parsedSource)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Verifying %s against %s...\n", localDir, sourceURL)
+
+	result, err := diff.CompareObjects(objects, parsedSource.Prefix, localDir, diff.Options{
+		Checksum: true,
+		Pattern:  parsedSource.Pattern,
+	})
+	if err != nil {
+		return fmt.Errorf("verify failed: %w", err)
+	}
+	excludeManifests(result)
+
+	byKey := make(map[string]providers.Object, len(objects))
+	for _, obj := range objects {
+		byKey[obj.Key] = obj
+	}
+	if err := splitUnverifiable(ctx, provider, result, byKey); err != nil {
+		return fmt.Errorf("verify failed: %w", err)
+	}
+
+	for _, change := range result.Changes() {
+		switch change.Type {
+		case diff.Modified:
+			fmt.Printf("CORRUPTED %s (%s)\n", change.Path, change.Reason)
+		case diff.Added:
+			fmt.Printf("MISSING   %s\n", change.Path)
+		case diff.Removed:
+			fmt.Printf("EXTRA     %s\n", change.Path)
+		}
+	}
+	for _, change := range result.Unverifiable {
+		fmt.Printf("UNVERIFIABLE %s (ETag is not an MD5 of the content)\n", change.Path)
+	}
+
+	fmt.Printf("\nVerified %d objects: %d corrupted, %d missing, %d extra, %d unverifiable\n",
+		len(objects), len(result.Modified), len(result.Added), len(result.Removed), len(result.Unverifiable))
+
+	bad := len(result.Modified) + len(result.Added)
+	if bad == 0 {
+		return nil
+	}
+
+	if !repair {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%w: found %d corrupted or missing files", downloader.ErrVerificationFailed, bad)
+	}
+
+	// Download only the bad files, at their pinned versions when verifying a lockfile
+	var toRepair []providers.Object
+	for _, change := range result.Changes() {
+		if change.Type != diff.Removed {
+			toRepair = append(toRepair, byKey[change.Key])
+		}
+	}
+
+	dl := downloader.NewDownloader(provider, downloader.Options{
+		Concurrency: concurrency,
+		Logger:      logger,
+	})
+
+	fmt.Printf("Repairing %d files...\n", len(toRepair))
+
+	repairResult, err := dl.DownloadObjects(ctx, toRepair, parsedSource.Prefix, localDir, nil)
+	if err != nil {
+		return fmt.Errorf("repair failed: %w", err)
+	}
+
+	return printResult(os.Stdout, "Repair completed!", repairResult)
+}
+
+// excludeManifests drops the checksum manifests written by clone --checksums from the
+// extra files; they are part of the clone, not stray files
+func excludeManifests(result *diff.Result) {
+	manifests := map[string]bool{
+		downloader.ChecksumSHA256.Filename(): true,
+		downloader.ChecksumMD5.Filename():    true,
+	}
+
+	removed := []diff.Change{}
+	for _, change := range result.Removed {
+		if !manifests[change.Path] {
+			removed = append(removed, change)
+		}
+	}
+	result.Removed = removed
+}
+
+// splitUnverifiable moves the files whose checksum differs from an ETag that is not an MD5
+// of the content, which only a HEAD request tells, from the modified files to the unverifiable ones
+func splitUnverifiable(ctx context.Context, provider providers.Provider, result *diff.Result, objects map[string]providers.Object) error {
+	modified := []diff.Change{}
+	for _, change := range result.Modified {
+		if change.Reason == diff.ReasonChecksum {
+			info, err := headObject(ctx, provider, objects[change.Key])
+			if err != nil {
+				return err
+			}
+			if !info.ETagIsMD5() {
+				change.Type = diff.Unverifiable
+				result.Unverifiable = append(result.Unverifiable, change)
+				continue
+			}
+		}
+		modified = append(modified, change)
+	}
+	result.Modified = modified
+
+	return nil
+}
+
+// headObject fetches the metadata of an object, at its pinned version if it has one
+func headObject(ctx context.Context, provider providers.Provider, obj providers.Object) (*providers.Object, error) {
+	if obj.VersionID != "" {
+		return provider.GetObjectVersionInfo(ctx, obj.Key, obj.VersionID)
+	}
+	return provider.GetObjectInfo(ctx, obj.Key)
+}
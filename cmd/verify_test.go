@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"download-file-from-bucket/diff"
+	"download-file-from-bucket/providers"
+)
+
+// encryptedProvider answers HEAD requests with the encryption of each key and counts them
+type encryptedProvider struct {
+	providers.Provider
+	encryption map[string]string
+	heads      int
+}
+
+func (p *encryptedProvider) GetObjectInfo(ctx context.Context, key string) (*providers.Object, error) {
+	p.heads++
+	encryption, ok := p.encryption[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, providers.ErrNotFound)
+	}
+	return &providers.Object{Key: key, Encryption: encryption}, nil
+}
+
+func TestSplitUnverifiable(t *testing.T) {
+	provider := &encryptedProvider{encryption: map[string]string{
+		"data/plain":  "",
+		"data/sse-s3": "AES256",
+		"data/kms":    "aws:kms",
+		"data/sse-c":  providers.EncryptionSSEC,
+	}}
+	objects := map[string]providers.Object{}
+	result := &diff.Result{}
+	for _, key := range []string{"data/plain", "data/sse-s3", "data/kms", "data/sse-c"} {
+		objects[key] = providers.Object{Key: key}
+		result.Modified = append(result.Modified, diff.Change{Path: key[len("data/"):], Key: key, Type: diff.Modified, Reason: diff.ReasonChecksum})
+	}
+	// A size mismatch is corruption whatever the ETag, so it needs no HEAD
+	result.Modified = append(result.Modified, diff.Change{Path: "short", Key: "data/short", Type: diff.Modified, Reason: "size 1 != 2"})
+
+	if err := splitUnverifiable(context.Background(), provider, result, objects); err != nil {
+		t.Fatal(err)
+	}
+
+	var corrupted, skipped []string
+	for _, change := range result.Modified {
+		corrupted = append(corrupted, change.Path)
+	}
+	for _, change := range result.Unverifiable {
+		skipped = append(skipped, change.Path)
+		if change.Type != diff.Unverifiable {
+			t.Errorf("%s: type %s, want %s", change.Path, change.Type, diff.Unverifiable)
+		}
+	}
+	if fmt.Sprint(corrupted) != "[plain sse-s3 short]" || fmt.Sprint(skipped) != "[kms sse-c]" {
+		t.Errorf("corrupted %v, unverifiable %v; want [plain sse-s3 short], [kms sse-c]", corrupted, skipped)
+	}
+	if provider.heads != 4 {
+		t.Errorf("made %d HEAD requests, want 4", provider.heads)
+	}
+}
+
+func TestExcludeManifests(t *testing.T) {
+	result := &diff.Result{Removed: []diff.Change{
+		{Path: "MD5SUMS", Type: diff.Removed},
+		{Path: "SHA256SUMS", Type: diff.Removed},
+		{Path: "stray.txt", Type: diff.Removed},
+		{Path: "nested/SHA256SUMS", Type: diff.Removed},
+	}}
+
+	excludeManifests(result)
+
+	var extra []string
+	for _, change := range result.Removed {
+		extra = append(extra, change.Path)
+	}
+	if fmt.Sprint(extra) != "[stray.txt nested/SHA256SUMS]" {
+		t.Errorf("extra files %v, want [stray.txt nested/SHA256SUMS]", extra)
+	}
+}
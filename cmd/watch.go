@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/watch"
+)
+
+var (
+	watchMode     bool
+	watchInterval time.Duration
+	watchState    string
+	watchEvents   string
+)
+
+// validateWatch checks that the other clone options can be used with --watch
+func validateWatch(sourceCount int, destDir string) error {
+	switch {
+	case lockedFile != "" || asOf != "":
+		return usageError{fmt.Errorf("--watch cannot be combined with --locked or --as-of")}
+	case sourceCount > 1:
+		return usageError{fmt.Errorf("--watch supports a single source")}
+	case checksums != "":
+		return usageError{fmt.Errorf("--watch cannot be combined with --checksums")}
+	case watchInterval <= 0:
+		return usageError{fmt.Errorf("--interval must be positive")}
+	case watchEvents != "text" && watchEvents != "json":
+		return usageError{fmt.Errorf("invalid --events %q: must be text or json", watchEvents)}
+	}
+
+	if destinationFormat(destDir) != formatDir {
+		return usageError{fmt.Errorf("--watch needs a directory destination")}
+	}
+
+	return nil
+}
+
+// runWatch downloads new and changed objects from source into destDir every --interval until interrupted
+func runWatch(dl *downloader.Downloader, source downloader.Source, destDir string, out io.Writer) error {
+	stateFile := watchState
+	if stateFile == "" {
+		stateFile = filepath.Clean(destDir) + ".watch.json"
+	}
+
+	sink := downloader.NewDirSink(destDir)
+	watcher, err := watch.NewWatcher(dl, source, sink, stateFile, func(event watch.Event) {
+		printEvent(out, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Stop cleanly on Ctrl-C or SIGTERM, after saving what was downloaded
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("watching", "prefix", source.Prefix, "interval", watchInterval, "state_file", stateFile)
+
+	return watcher.Run(ctx, watchInterval, func(result *downloader.DownloadResult, err error) {
+		if err != nil {
+			logger.Error("poll failed", "error", err)
+			return
+		}
+		if err := runCompletionHook(ctx, os.Stderr, destDir, result); err != nil {
+			logger.Error("hook error", "error", err)
+		}
+	})
+}
+
+// printEvent writes a watch event in the --events format
+func printEvent(out io.Writer, event watch.Event) {
+	if watchEvents == "json" {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(out, "%s\n", data)
+		return
+	}
+
+	if event.Type == watch.EventFailed {
+		fmt.Fprintf(out, "%s %s %s: %s\n", event.Time.Format(time.RFC3339), event.Type, event.Key, event.Error)
+		return
+	}
+	fmt.Fprintf(out, "%s %s %s (%d bytes)\n", event.Time.Format(time.RFC3339), event.Type, event.Key, event.Size)
+}
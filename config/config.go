@@ -1,9 +1,11 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
@@ -22,7 +24,11 @@ type ProviderConfig struct {
 	AccessKey string            `yaml:"access_key"`
 	SecretKey string            `yaml:"secret_key"`
 	Bucket    string            `yaml:"bucket"`
-	Options   map[string]string `yaml:"options"`   // Additional provider-specific options
+	Options   map[string]string `yaml:"options"` // Additional provider-specific options
+
+	// SSE-C customer keys are never stored in the config file itself, only where to find them
+	SSECustomerKeyFile string `yaml:"sse_c_key_file,omitempty"` // File holding the raw or base64-encoded 256-bit key
+	SSECustomerKeyEnv  string `yaml:"sse_c_key_env,omitempty"`  // Environment variable holding the base64-encoded key
 }
 
 // LoadConfig loads configuration from file or environment variables
@@ -114,6 +120,46 @@ func (c *Config) SaveConfig(filename string) error {
 	return nil
 }
 
+// SSECustomerKey loads the SSE-C customer key referenced by the provider config.
+// It returns nil if no key is configured.
+func (pc ProviderConfig) SSECustomerKey() ([]byte, error) {
+	var raw []byte
+	var source string
+
+	switch {
+	case pc.SSECustomerKeyFile != "":
+		data, err := os.ReadFile(pc.SSECustomerKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading SSE-C key file: %w", err)
+		}
+		raw = data
+		source = pc.SSECustomerKeyFile
+	case pc.SSECustomerKeyEnv != "":
+		value := os.Getenv(pc.SSECustomerKeyEnv)
+		if value == "" {
+			return nil, fmt.Errorf("SSE-C key environment variable %s is not set", pc.SSECustomerKeyEnv)
+		}
+		raw = []byte(value)
+		source = "$" + pc.SSECustomerKeyEnv
+	default:
+		return nil, nil
+	}
+
+	// Accept either the raw key bytes or their base64 encoding
+	if len(raw) == sseCustomerKeySize {
+		return raw, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil || len(decoded) != sseCustomerKeySize {
+		return nil, fmt.Errorf("SSE-C key in %s must be %d raw bytes or their base64 encoding", source, sseCustomerKeySize)
+	}
+
+	return decoded, nil
+}
+
+// sseCustomerKeySize is the length of an AES-256 key
+const sseCustomerKeySize = 32
+
 // getEnvOrDefault returns environment variable value or default if not set
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
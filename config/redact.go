@@ -0,0 +1,86 @@
+package config
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// redacted replaces secrets in logged configuration
+const redacted = "REDACTED"
+
+// secretOptionWords mark provider options whose values are secrets
+var secretOptionWords = []string{"key", "secret", "token", "password", "credential"}
+
+// LogValue logs the provider configuration with its credentials redacted,
+// so a ProviderConfig can be passed to a logger as it is
+func (pc ProviderConfig) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("type", pc.Type),
+		slog.String("region", pc.Region),
+		slog.String("endpoint", pc.Endpoint),
+		slog.String("bucket", pc.Bucket),
+		slog.String("access_key", redact(pc.AccessKey)),
+		slog.String("secret_key", redact(pc.SecretKey)),
+	}
+	if pc.SSECustomerKeyFile != "" {
+		attrs = append(attrs, slog.String("sse_c_key_file", pc.SSECustomerKeyFile))
+	}
+	if pc.SSECustomerKeyEnv != "" {
+		attrs = append(attrs, slog.String("sse_c_key_env", pc.SSECustomerKeyEnv))
+	}
+
+	if len(pc.Options) > 0 {
+		names := make([]string, 0, len(pc.Options))
+		for name := range pc.Options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var options []any
+		for _, name := range names {
+			value := pc.Options[name]
+			if isSecretOption(name) {
+				value = redact(value)
+			}
+			options = append(options, slog.String(name, value))
+		}
+		attrs = append(attrs, slog.Group("options", options...))
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue logs every provider with its credentials redacted
+func (c Config) LogValue() slog.Value {
+	names := make([]string, 0, len(c.Providers))
+	for name := range c.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]slog.Attr, 0, len(names))
+	for _, name := range names {
+		attrs = append(attrs, slog.Any(name, c.Providers[name]))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// redact hides a secret, keeping only whether it was set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// isSecretOption reports whether a provider option holds a secret, judging by its name
+func isSecretOption(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range secretOptionWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
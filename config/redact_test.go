@@ -0,0 +1,65 @@
+package config
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggedConfigHidesSecrets(t *testing.T) {
+	const (
+		accessKey = "AKIAEXAMPLEACCESS"
+		secretKey = "wJalrXUtnFEMI-example-secret"
+		sseCKey   = "c2VjcmV0LXNzZS1jLWtleS1vZi0zMi1ieXRlcyE="
+		token     = "session-token-example"
+	)
+	provider := ProviderConfig{
+		Type:      "s3",
+		Region:    "eu-west-1",
+		Bucket:    "backups",
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Options: map[string]string{
+			"sse_customer_key": sseCKey,
+			"session_token":    token,
+			"storage_class":    "GLACIER",
+		},
+		SSECustomerKeyEnv: "BACKUP_SSE_KEY",
+	}
+	config := Config{Providers: map[string]ProviderConfig{"main": provider}}
+
+	handlers := map[string]func(*bytes.Buffer) slog.Handler{
+		"json": func(buf *bytes.Buffer) slog.Handler { return slog.NewJSONHandler(buf, nil) },
+		"text": func(buf *bytes.Buffer) slog.Handler { return slog.NewTextHandler(buf, nil) },
+	}
+
+	for name, newHandler := range handlers {
+		var buf bytes.Buffer
+		logger := slog.New(newHandler(&buf))
+		logger.Info("provider", "provider", provider)
+		logger.Info("provider pointer", "provider", &provider)
+		logger.Info("config", "config", config)
+		out := buf.String()
+
+		for _, secret := range []string{accessKey, secretKey, sseCKey, token} {
+			if strings.Contains(out, secret) {
+				t.Errorf("%s: secret %q was logged:\n%s", name, secret, out)
+			}
+		}
+		for _, want := range []string{"eu-west-1", "backups", "GLACIER", "BACKUP_SSE_KEY", redacted} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: %q missing from the log:\n%s", name, want, out)
+			}
+		}
+	}
+}
+
+func TestRedactKeepsUnsetSecretsEmpty(t *testing.T) {
+	if got := redact(""); got != "" {
+		t.Errorf("redact(\"\") = %q, want empty", got)
+	}
+	if got := redact("x"); got != redacted {
+		t.Errorf("redact(\"x\") = %q, want %q", got, redacted)
+	}
+}
@@ -0,0 +1,168 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"download-file-from-bucket/checksum"
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/glob"
+	"download-file-from-bucket/localfiles"
+	"download-file-from-bucket/providers"
+)
+
+// ChangeType describes how a file differs between the bucket and the local directory
+type ChangeType string
+
+const (
+	// Added means the object is in the bucket but not in the local directory
+	Added ChangeType = "added"
+	// Removed means the file is in the local directory but not in the bucket
+	Removed ChangeType = "removed"
+	// Modified means the file exists on both sides with different content
+	Modified ChangeType = "modified"
+	// Unverifiable means the content did not match an ETag that is not an MD5 of the content,
+	// as for SSE-KMS and SSE-C objects, so the file may well be intact
+	Unverifiable ChangeType = "unverifiable"
+)
+
+// ReasonChecksum is the reason given for files whose size matches but whose content does not match the ETag
+const ReasonChecksum = "checksum differs"
+
+// Change is a single difference between the bucket and the local directory
+type Change struct {
+	Path   string     `json:"path"`
+	Key    string     `json:"key,omitempty"`
+	Type   ChangeType `json:"type"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+// Result lists every difference found, sorted by path
+type Result struct {
+	Added    []Change `json:"added"`
+	Removed  []Change `json:"removed"`
+	Modified []Change `json:"modified"`
+	// Unverifiable files are not differences; they are not counted or included in Changes
+	Unverifiable []Change `json:"unverifiable,omitempty"`
+}
+
+// Options for configuring the comparison
+type Options struct {
+	// Checksum compares file content with the object ETag instead of comparing modification times
+	Checksum bool
+	// PartSize is the multipart part size assumed when checking multipart ETags
+	PartSize int64
+	// Pattern, if set, limits the comparison to paths that match it
+	Pattern *glob.Pattern
+}
+
+// Count returns the total number of differences
+func (r *Result) Count() int {
+	return len(r.Added) + len(r.Removed) + len(r.Modified)
+}
+
+// Changes returns all differences in a single list, sorted by path
+func (r *Result) Changes() []Change {
+	changes := make([]Change, 0, r.Count())
+	changes = append(changes, r.Added...)
+	changes = append(changes, r.Removed...)
+	changes = append(changes, r.Modified...)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// CompareObjects reports how the files under localDir differ from a known list of objects,
+// such as the entries of a lockfile
+func CompareObjects(objects []providers.Object, prefix, localDir string, opts Options) (*Result, error) {
+	if opts.PartSize <= 0 {
+		opts.PartSize = checksum.DefaultPartSize
+	}
+
+	files, err := localfiles.List(localDir)
+	if err != nil {
+		return nil, err
+	}
+	local := make(map[string]localfiles.File, len(files))
+	for _, file := range files {
+		local[file.Name] = file
+	}
+
+	result := &Result{
+		Added:    []Change{},
+		Removed:  []Change{},
+		Modified: []Change{},
+	}
+
+	seen := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		// Directory markers have no local counterpart to compare
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+
+		name := downloader.LocalName(obj.Key, prefix)
+		if !opts.Pattern.Match(name) {
+			continue
+		}
+		seen[name] = true
+
+		file, exists := local[name]
+		if !exists {
+			result.Added = append(result.Added, Change{Path: name, Key: obj.Key, Type: Added})
+			continue
+		}
+
+		reason, err := compareFile(file, obj, opts)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			result.Modified = append(result.Modified, Change{Path: name, Key: obj.Key, Type: Modified, Reason: reason})
+		}
+	}
+
+	for name := range local {
+		if !seen[name] && opts.Pattern.Match(name) {
+			result.Removed = append(result.Removed, Change{Path: name, Type: Removed})
+		}
+	}
+
+	for _, changes := range [][]Change{result.Added, result.Removed, result.Modified} {
+		sort.Slice(changes, func(i, j int) bool {
+			return changes[i].Path < changes[j].Path
+		})
+	}
+
+	return result, nil
+}
+
+// compareFile explains why a local file differs from its object, or returns an empty string if it does not
+func compareFile(file localfiles.File, obj providers.Object, opts Options) (string, error) {
+	if file.Size != obj.Size {
+		return fmt.Sprintf("size %d != %d", file.Size, obj.Size), nil
+	}
+
+	if opts.Checksum {
+		same, err := checksum.FileMatchesETag(file.Path, obj.ETag, opts.PartSize)
+		if err != nil {
+			return "", fmt.Errorf("failed to check %s: %w", file.Path, err)
+		}
+		if !same {
+			return ReasonChecksum, nil
+		}
+		return "", nil
+	}
+
+	// LastModified has one second resolution
+	localTime := file.ModTime.Truncate(time.Second)
+	remoteTime := obj.LastModified.Truncate(time.Second)
+	if !localTime.Equal(remoteTime) {
+		return fmt.Sprintf("mtime %s != %s", localTime.UTC().Format(time.RFC3339), remoteTime.UTC().Format(time.RFC3339)), nil
+	}
+
+	return "", nil
+}
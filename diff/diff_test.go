@@ -0,0 +1,55 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"download-file-from-bucket/providers"
+)
+
+func TestCompareObjectsUsesSanitizedNames(t *testing.T) {
+	modified := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	objects := []providers.Object{
+		{Key: "data/a//b.txt", Size: 1, LastModified: modified},
+		{Key: "data/../up.txt", Size: 2, LastModified: modified},
+		{Key: "data/bell\a.txt", Size: 3, LastModified: modified},
+		{Key: "data/missing.txt", Size: 4, LastModified: modified},
+	}
+
+	// The files as clone writes them, plus one the bucket does not have
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a/b.txt":      "1",
+		"__/up.txt":    "22",
+		"bell_.txt":    "333",
+		"leftover.txt": "5",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := CompareObjects(objects, "data/", dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Modified) != 0 {
+		t.Errorf("modified = %+v, want none", result.Modified)
+	}
+	if len(result.Added) != 1 || result.Added[0].Path != "missing.txt" || result.Added[0].Key != "data/missing.txt" {
+		t.Errorf("added = %+v, want only missing.txt", result.Added)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].Path != "leftover.txt" {
+		t.Errorf("removed = %+v, want only leftover.txt", result.Removed)
+	}
+}
@@ -0,0 +1,41 @@
+package downloader
+
+import "context"
+
+// Budget limits how many objects are downloaded at once across every downloader that shares it.
+// Each downloader still runs its own workers; a worker waits for a slot before each object.
+type Budget struct {
+	slots chan struct{}
+}
+
+// NewBudget creates a budget allowing n downloads at once
+func NewBudget(n int) *Budget {
+	if n <= 0 {
+		n = 1
+	}
+	return &Budget{slots: make(chan struct{}, n)}
+}
+
+// acquire waits for a free slot or for ctx to be cancelled.
+// A nil budget never waits, but still refuses once ctx is cancelled.
+func (b *Budget) acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if b == nil {
+		return nil
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a slot taken by acquire
+func (b *Budget) release() {
+	if b != nil {
+		<-b.slots
+	}
+}
@@ -0,0 +1,157 @@
+package downloader
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"download-file-from-bucket/providers"
+)
+
+// ChecksumAlgorithm selects the hash used for the checksum manifest
+type ChecksumAlgorithm string
+
+const (
+	// ChecksumNone writes no manifest
+	ChecksumNone ChecksumAlgorithm = ""
+	// ChecksumSHA256 writes a SHA256SUMS file
+	ChecksumSHA256 ChecksumAlgorithm = "sha256"
+	// ChecksumMD5 writes an MD5SUMS file
+	ChecksumMD5 ChecksumAlgorithm = "md5"
+)
+
+// ParseChecksumAlgorithm validates the name of a checksum algorithm
+func ParseChecksumAlgorithm(name string) (ChecksumAlgorithm, error) {
+	switch algorithm := ChecksumAlgorithm(strings.ToLower(name)); algorithm {
+	case ChecksumNone, ChecksumSHA256, ChecksumMD5:
+		return algorithm, nil
+	}
+	return ChecksumNone, fmt.Errorf("unsupported checksum algorithm %q: must be sha256 or md5", name)
+}
+
+// Filename returns the name of the manifest, as used by the coreutils tools
+func (a ChecksumAlgorithm) Filename() string {
+	switch a {
+	case ChecksumSHA256:
+		return "SHA256SUMS"
+	case ChecksumMD5:
+		return "MD5SUMS"
+	}
+	return ""
+}
+
+// newHash creates a hash for the algorithm
+func (a ChecksumAlgorithm) newHash() hash.Hash {
+	if a == ChecksumMD5 {
+		return md5.New()
+	}
+	return sha256.New()
+}
+
+// manifest collects the checksums of the files written to a sink
+type manifest struct {
+	algorithm ChecksumAlgorithm
+	mu        sync.Mutex
+	sums      map[string]string
+}
+
+// newManifest creates an empty manifest
+func newManifest(algorithm ChecksumAlgorithm) *manifest {
+	return &manifest{
+		algorithm: algorithm,
+		sums:      make(map[string]string),
+	}
+}
+
+// add records the checksum of a file
+func (m *manifest) add(name, sum string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sums[name] = sum
+}
+
+// writeTo stores the manifest in the sink, one "<sum>  <name>" line per file in the format
+// read by sha256sum -c and md5sum -c
+func (m *manifest) writeTo(sink Sink) error {
+	m.mu.Lock()
+	names := make([]string, 0, len(m.sums))
+	for name := range m.sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		// Names with backslashes or newlines are escaped and the line marked with a leading backslash
+		if strings.ContainsAny(name, "\\\n") {
+			escaped := strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+			fmt.Fprintf(&buf, "\\%s  %s\n", m.sums[name], escaped)
+		} else {
+			fmt.Fprintf(&buf, "%s  %s\n", m.sums[name], name)
+		}
+	}
+	m.mu.Unlock()
+
+	filename := m.algorithm.Filename()
+	if _, err := sink.WriteFile(filename, &buf, time.Now()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// checksumSink hashes every file as it streams into the wrapped sink
+type checksumSink struct {
+	Sink
+	manifest *manifest
+}
+
+// checksumObjectSink is a checksumSink around a sink that keeps object properties
+type checksumObjectSink struct {
+	*checksumSink
+	objectSink ObjectSink
+}
+
+// newChecksumSink wraps sink so the checksum of each written file is recorded in m.
+// The wrapper is an ObjectSink only if sink is, so no extra metadata requests are made.
+func newChecksumSink(sink Sink, m *manifest) Sink {
+	cs := &checksumSink{Sink: sink, manifest: m}
+	if objectSink, ok := sink.(ObjectSink); ok {
+		return &checksumObjectSink{checksumSink: cs, objectSink: objectSink}
+	}
+	return cs
+}
+
+// WriteFile writes the file to the wrapped sink and records its checksum
+func (s *checksumSink) WriteFile(name string, r io.Reader, modTime time.Time) (int64, error) {
+	return s.hash(name, r, func(r io.Reader) (int64, error) {
+		return s.Sink.WriteFile(name, r, modTime)
+	})
+}
+
+// WriteObject writes the object to the wrapped sink and records its checksum
+func (s *checksumObjectSink) WriteObject(name string, r io.Reader, obj providers.Object) (int64, error) {
+	return s.hash(name, r, func(r io.Reader) (int64, error) {
+		return s.objectSink.WriteObject(name, r, obj)
+	})
+}
+
+// hash passes r through a hash on its way to write and records the sum if the write succeeds
+func (s *checksumSink) hash(name string, r io.Reader, write func(io.Reader) (int64, error)) (int64, error) {
+	h := s.manifest.algorithm.newHash()
+	n, err := write(io.TeeReader(r, h))
+	if err != nil {
+		return n, err
+	}
+
+	s.manifest.add(name, hex.EncodeToString(h.Sum(nil)))
+	return n, nil
+}
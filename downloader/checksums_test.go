@@ -0,0 +1,183 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// parseManifest reads a manifest as sha256sum -c and md5sum -c do, returning the sums by name
+func parseManifest(t *testing.T, content string) map[string]string {
+	t.Helper()
+
+	sums := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		escaped := strings.HasPrefix(line, "\\")
+		line = strings.TrimPrefix(line, "\\")
+
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			t.Fatalf("malformed manifest line %q", line)
+		}
+		if escaped {
+			var b strings.Builder
+			for i := 0; i < len(name); i++ {
+				if name[i] == '\\' && i+1 < len(name) {
+					i++
+					if name[i] == 'n' {
+						b.WriteByte('\n')
+						continue
+					}
+				}
+				b.WriteByte(name[i])
+			}
+			name = b.String()
+		}
+		sums[name] = sum
+	}
+	return sums
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseChecksumAlgorithm(t *testing.T) {
+	for name, want := range map[string]ChecksumAlgorithm{"": ChecksumNone, "sha256": ChecksumSHA256, "SHA256": ChecksumSHA256, "md5": ChecksumMD5} {
+		got, err := ParseChecksumAlgorithm(name)
+		if err != nil || got != want {
+			t.Errorf("ParseChecksumAlgorithm(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseChecksumAlgorithm("crc32"); err == nil {
+		t.Error("expected an error for crc32")
+	}
+}
+
+func TestManifestEscapesNames(t *testing.T) {
+	m := newManifest(ChecksumSHA256)
+	names := []string{"plain.txt", `dir\file`, "new\nline", "both\\\n"}
+	for _, name := range names {
+		m.add(name, sha256Hex(name))
+	}
+
+	sink := newMemorySink()
+	if err := m.writeTo(sink); err != nil {
+		t.Fatal(err)
+	}
+	content := sink.files["SHA256SUMS"]
+
+	want := strings.Join([]string{
+		"\\" + sha256Hex("both\\\n") + `  both\\\n`,
+		"\\" + sha256Hex(`dir\file`) + `  dir\\file`,
+		"\\" + sha256Hex("new\nline") + `  new\nline`,
+		sha256Hex("plain.txt") + "  plain.txt",
+	}, "\n") + "\n"
+	if content != want {
+		t.Errorf("manifest:\n%s\nwant:\n%s", content, want)
+	}
+
+	got := parseManifest(t, content)
+	for _, name := range names {
+		if got[name] != sha256Hex(name) {
+			t.Errorf("%q did not survive the round trip: %v", name, got)
+		}
+	}
+}
+
+func TestChecksumManifestInDirectory(t *testing.T) {
+	provider := newFakeProvider(map[string]string{
+		"data/a.txt":       "alpha",
+		"data/dir/b.txt":   "bravo",
+		"data/broken.txt":  "never written",
+		"data/dir/c/d.bin": strings.Repeat("x", 70000),
+	})
+	provider.failures["data/broken.txt"] = errors.New("boom")
+
+	dir := t.TempDir()
+	d := NewDownloader(provider, Options{Concurrency: 3, Checksums: ChecksumSHA256})
+	if _, err := d.DownloadFolder(context.Background(), "data/", dir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The manifest sits at the root, where verify expects it
+	content, err := os.ReadFile(filepath.Join(dir, ChecksumSHA256.Filename()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums := parseManifest(t, string(content))
+
+	want := []string{"a.txt", "dir/b.txt", "dir/c/d.bin"}
+	if !reflect.DeepEqual(keysSorted(sums), want) {
+		t.Errorf("manifest lists %v, want %v", keysSorted(sums), want)
+	}
+	for name, sum := range sums {
+		local, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sha256Hex(string(local)) != sum {
+			t.Errorf("%s: manifest sum does not match the file", name)
+		}
+	}
+}
+
+func TestChecksumManifestInArchive(t *testing.T) {
+	provider := newFakeProvider(map[string]string{"one/a.txt": "alpha", "two/b.txt": "bravo"})
+	sources := []Source{{Prefix: "one/", Dir: "one"}, {Prefix: "two/", Dir: "two"}}
+
+	var buf bytes.Buffer
+	sink := NewTarSink(&buf, false)
+	d := NewDownloader(provider, Options{Checksums: ChecksumMD5})
+	if _, err := d.DownloadSources(context.Background(), sources, sink, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := readTar(t, &buf)
+	manifest, ok := files[ChecksumMD5.Filename()]
+	if !ok {
+		t.Fatalf("archive holds %v, want %s at its root", keysOf(files), ChecksumMD5.Filename())
+	}
+
+	sums := parseManifest(t, manifest)
+	if !reflect.DeepEqual(keysSorted(sums), []string{"one/a.txt", "two/b.txt"}) {
+		t.Errorf("manifest lists %v", keysSorted(sums))
+	}
+	for name, sum := range sums {
+		if got := md5.Sum([]byte(files[name])); hex.EncodeToString(got[:]) != sum {
+			t.Errorf("%s: manifest sum does not match the archive entry", name)
+		}
+	}
+}
+
+func TestChecksumSinkKeepsObjectSink(t *testing.T) {
+	sink := newChecksumSink(NewProviderSink(context.Background(), newFakeProvider(nil), ""), newManifest(ChecksumSHA256))
+	if _, ok := sink.(ObjectSink); !ok {
+		t.Error("wrapping an ObjectSink lost its object properties")
+	}
+	if _, ok := newChecksumSink(newMemorySink(), newManifest(ChecksumSHA256)).(ObjectSink); ok {
+		t.Error("wrapping a plain sink made it an ObjectSink")
+	}
+}
+
+func keysSorted(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,98 @@
+package downloader
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// codec identifies a compression format
+type codec string
+
+const (
+	codecNone  codec = ""
+	codecGzip  codec = "gzip"
+	codecZstd  codec = "zstd"
+	codecBzip2 codec = "bzip2"
+	codecXz    codec = "xz"
+)
+
+// codecExtensions maps file extensions to the compression format they indicate
+var codecExtensions = map[string]codec{
+	".gz":  codecGzip,
+	".zst": codecZstd,
+	".bz2": codecBzip2,
+	".xz":  codecXz,
+}
+
+// codecContentTypes maps MIME types to the compression format they indicate
+var codecContentTypes = map[string]codec{
+	"application/gzip":    codecGzip,
+	"application/x-gzip":  codecGzip,
+	"application/zstd":    codecZstd,
+	"application/x-bzip2": codecBzip2,
+	"application/x-xz":    codecXz,
+}
+
+// codecFromName detects compression from a file extension and returns the name with the extension removed
+func codecFromName(name string) (codec, string) {
+	if strings.HasSuffix(name, ".tgz") {
+		return codecGzip, strings.TrimSuffix(name, ".tgz") + ".tar"
+	}
+	for ext, c := range codecExtensions {
+		if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+			return c, strings.TrimSuffix(name, ext)
+		}
+	}
+	return codecNone, name
+}
+
+// codecFromHeaders detects compression from an object's Content-Encoding or Content-Type
+func codecFromHeaders(contentEncoding, contentType string) codec {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "gzip", "x-gzip":
+		return codecGzip
+	case "zstd":
+		return codecZstd
+	case "bzip2":
+		return codecBzip2
+	case "xz":
+		return codecXz
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return codecNone
+	}
+	return codecContentTypes[mediaType]
+}
+
+// newDecompressor wraps r with a reader that undoes the given compression
+func newDecompressor(c codec, r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case codecGzip:
+		return gzip.NewReader(r)
+	case codecZstd:
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	case codecBzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case codecXz:
+		dec, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(dec), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", c)
+	}
+}
@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func gzipped(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDecompressUsesDownloadHeaders(t *testing.T) {
+	provider := newFakeProvider(map[string]string{
+		"logs/a.log.gz": gzipped(t, "by extension"),
+		"logs/b.log":    gzipped(t, "by encoding"),
+		"logs/c.txt":    "plain",
+	})
+	provider.encodings["logs/b.log"] = "gzip"
+
+	dir := t.TempDir()
+	result, err := NewDownloader(provider, Options{Decompress: true}).DownloadFolder(context.Background(), "logs/", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FailedFiles != 0 {
+		t.Fatalf("download failed: %v", result.Errors)
+	}
+
+	for name, want := range map[string]string{"a.log": "by extension", "b.log": "by encoding", "c.txt": "plain"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(content) != want {
+			t.Errorf("%s = %q, %v; want %q", name, content, err, want)
+		}
+	}
+	if provider.infoRequests != 0 {
+		t.Errorf("made %d metadata requests, want none", provider.infoRequests)
+	}
+}
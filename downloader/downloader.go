@@ -4,25 +4,65 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"download-file-from-bucket/encryption"
+	"download-file-from-bucket/glob"
+	"download-file-from-bucket/metrics"
 	"download-file-from-bucket/providers"
+	"download-file-from-bucket/tracing"
 )
 
 // Downloader handles downloading files from cloud storage
 type Downloader struct {
-	provider    providers.Provider
-	concurrency int
-	verbose     bool
+	provider        providers.Provider
+	concurrency     int
+	skipArchived    bool
+	decrypter       *encryption.Decrypter
+	decompress      bool
+	extract         bool
+	checksums       ChecksumAlgorithm
+	filter          Filter
+	paths           *PathMapper
+	onFile          FileHook
+	hookConcurrency int
+	budget          *Budget
+	failures        FailurePolicy
+	logger          *slog.Logger
 }
 
 // Options for configuring the downloader
 type Options struct {
 	Concurrency int
-	Verbose     bool
+	// SkipArchived skips objects in archive storage classes that have no restored copy
+	SkipArchived bool
+	// Decrypter, if set, decrypts objects written by the S3 encryption client before they are saved
+	Decrypter *encryption.Decrypter
+	// Decompress undoes gzip, zstd, bzip2 and xz compression while downloading
+	Decompress bool
+	// Extract unpacks tar and zip archives into a directory named after the key
+	Extract bool
+	// Checksums, if set, writes a SHA256SUMS or MD5SUMS manifest of the downloaded files into the sink
+	Checksums ChecksumAlgorithm
+	// Filter selects which objects are downloaded by date, size, metadata and tags
+	Filter Filter
+	// Paths, if set, changes where objects are written instead of mirroring their keys
+	Paths *PathMapper
+	// OnFile, if set, is called for every file after it is downloaded, on a separate pool of workers
+	OnFile FileHook
+	// HookConcurrency is the number of OnFile calls that run at once
+	HookConcurrency int
+	// Budget, if set, is shared with other downloaders to cap the number of downloads running at once
+	Budget *Budget
+	// Failures decides when failures abort the download; by default every object is attempted
+	Failures FailurePolicy
+	// Logger receives progress at debug level; defaults to slog.Default()
+	Logger *slog.Logger
 }
 
 // NewDownloader creates a new downloader
@@ -30,12 +70,49 @@ func NewDownloader(provider providers.Provider, opts Options) *Downloader {
 	if opts.Concurrency <= 0 {
 		opts.Concurrency = 5 // Default concurrency
 	}
+	if opts.HookConcurrency <= 0 {
+		opts.HookConcurrency = 2 // Default hook concurrency
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
 
 	return &Downloader{
-		provider:    provider,
-		concurrency: opts.Concurrency,
-		verbose:     opts.Verbose,
+		provider:        provider,
+		concurrency:     opts.Concurrency,
+		skipArchived:    opts.SkipArchived,
+		decrypter:       opts.Decrypter,
+		decompress:      opts.Decompress,
+		extract:         opts.Extract,
+		checksums:       opts.Checksums,
+		filter:          opts.Filter,
+		paths:           opts.Paths,
+		onFile:          opts.OnFile,
+		hookConcurrency: opts.HookConcurrency,
+		budget:          opts.Budget,
+		failures:        opts.Failures,
+		logger:          opts.Logger,
+	}
+}
+
+// Source is a folder to download, possibly from its own bucket or provider
+type Source struct {
+	// Provider lists and downloads the objects; nil uses the downloader's provider
+	Provider providers.Provider
+	// Prefix is the folder to download; files are named by their keys relative to it
+	Prefix string
+	// Pattern, if set, keeps only objects whose names relative to Prefix match it
+	Pattern *glob.Pattern
+	// Dir is the directory in the sink the files are written under; empty writes them at the root
+	Dir string
+}
+
+// join places a name under the source's directory in the sink
+func (s *Source) join(name string) string {
+	if s.Dir == "" {
+		return name
 	}
+	return strings.TrimSuffix(s.Dir, "/") + "/" + name
 }
 
 // DownloadResult represents the result of a download operation
@@ -43,56 +120,206 @@ type DownloadResult struct {
 	TotalFiles      int
 	SuccessfulFiles int
 	FailedFiles     int
+	SkippedFiles    int
+	FailedHooks     int
 	TotalBytes      int64
 	Duration        time.Duration
 	Errors          []error
+	// HookErrors holds the failures of the file hook; the files themselves were downloaded
+	HookErrors []error
+	// Cancelled is set if the context was cancelled before every object was handled
+	Cancelled bool
+	// Aborted is why the failure policy stopped the download, wrapping ErrAborted and the last error
+	Aborted error
+	// RemainingFiles counts the selected objects that were not handled because the download was cancelled or aborted
+	RemainingFiles int
+	// Filtered holds the skipped objects that the pattern, filters or path mapping left out.
+	// Archived objects skipped until they are restored are not included.
+	Filtered []providers.Object
 }
 
 // DownloadFolder downloads all files from a folder/prefix to a local directory
 func (d *Downloader) DownloadFolder(ctx context.Context, prefix, localDir string, progressCallback func(providers.DownloadProgress)) (*DownloadResult, error) {
+	return d.DownloadFolderTo(ctx, prefix, NewDirSink(localDir), progressCallback)
+}
+
+// DownloadFolderTo downloads all files from a folder/prefix into a sink
+func (d *Downloader) DownloadFolderTo(ctx context.Context, prefix string, sink Sink, progressCallback func(providers.DownloadProgress)) (*DownloadResult, error) {
+	return d.DownloadSources(ctx, []Source{{Prefix: prefix}}, sink, progressCallback)
+}
+
+// DownloadSources downloads several folders, possibly from different buckets and providers, into one sink.
+// All sources share the worker pool and are reported in a single result.
+func (d *Downloader) DownloadSources(ctx context.Context, sources []Source, sink Sink, progressCallback func(providers.DownloadProgress)) (*DownloadResult, error) {
 	startTime := time.Now()
-	
-	// List all objects with the given prefix
-	if d.verbose {
-		fmt.Printf("Listing objects with prefix: %s\n", prefix)
-	}
-	
-	objects, err := d.provider.ListObjects(ctx, prefix)
+
+	batches, total, err := d.listSources(ctx, sources)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list objects: %w", err)
+		return nil, err
 	}
 
-	if len(objects) == 0 {
+	if total == 0 {
 		return &DownloadResult{
 			Duration: time.Since(startTime),
 		}, nil
 	}
 
-	if d.verbose {
-		fmt.Printf("Found %d objects to download\n", len(objects))
+	d.logger.DebugContext(ctx, "found objects to download", "count", total)
+
+	result, err := d.download(ctx, batches, sink, progressCallback)
+	if err != nil {
+		return nil, err
+	}
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
+
+// listSources lists the objects of every source, returning a batch per source and the number of objects found
+func (d *Downloader) listSources(ctx context.Context, sources []Source) ([]batch, int, error) {
+	var batches []batch
+	total := 0
+	for i := range sources {
+		source := &sources[i]
+		if source.Provider == nil {
+			source.Provider = d.provider
+		}
+
+		// List all objects with the given prefix, narrowed by the literal start of any key pattern
+		listPrefix := source.Prefix + source.Pattern.LiteralPrefix()
+		d.logger.DebugContext(ctx, "listing objects", "prefix", listPrefix)
+
+		listCtx, span := tracing.Start(ctx, "list", attribute.String("prefix", listPrefix))
+		objects, err := source.Provider.ListObjects(listCtx, listPrefix)
+		span.SetAttributes(attribute.Int("objects", len(objects)))
+		tracing.End(span, err)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		batches = append(batches, batch{source: source, objects: objects})
+		total += len(objects)
+	}
+
+	return batches, total, nil
+}
+
+// DownloadObjects downloads the given objects to a local directory, mirroring their keys relative to prefix.
+// Objects with a VersionID are downloaded at that exact version.
+func (d *Downloader) DownloadObjects(ctx context.Context, objects []providers.Object, prefix, localDir string, progressCallback func(providers.DownloadProgress)) (*DownloadResult, error) {
+	return d.DownloadObjectsTo(ctx, objects, prefix, NewDirSink(localDir), progressCallback)
+}
+
+// DownloadObjectsTo downloads the given objects into a sink, naming them by their keys relative to prefix
+func (d *Downloader) DownloadObjectsTo(ctx context.Context, objects []providers.Object, prefix string, sink Sink, progressCallback func(providers.DownloadProgress)) (*DownloadResult, error) {
+	return d.DownloadSourceObjects(ctx, Source{Prefix: prefix}, objects, sink, progressCallback)
+}
+
+// DownloadSourceObjects downloads the given objects of a source into a sink, instead of listing the source
+func (d *Downloader) DownloadSourceObjects(ctx context.Context, source Source, objects []providers.Object, sink Sink, progressCallback func(providers.DownloadProgress)) (*DownloadResult, error) {
+	if source.Provider == nil {
+		source.Provider = d.provider
+	}
+	return d.download(ctx, []batch{{source: &source, objects: objects}}, sink, progressCallback)
+}
+
+// batch is the list of objects found for one source
+type batch struct {
+	source  *Source
+	objects []providers.Object
+}
+
+// job is a single object to download, along with the source it came from
+type job struct {
+	source *Source
+	obj    providers.Object
+}
+
+// download selects the objects of every batch and downloads them with one pool of workers.
+// It fails without downloading anything if several objects would be written to the same name.
+func (d *Downloader) download(ctx context.Context, batches []batch, sink Sink, progressCallback func(providers.DownloadProgress)) (*DownloadResult, error) {
+	startTime := time.Now()
+
+	var queue []job
+	skipped := 0
+	var filtered []providers.Object
+	var selectErrors []error
+	selectCtx, span := tracing.Start(ctx, "select")
+	for _, b := range batches {
+		objects, skippedObjects, errs := d.selectObjects(selectCtx, b.source, b.objects)
+		for _, obj := range objects {
+			queue = append(queue, job{source: b.source, obj: obj})
+		}
+		skipped += len(skippedObjects)
+		for _, s := range skippedObjects {
+			if s.filtered {
+				filtered = append(filtered, s.obj)
+			}
+		}
+		selectErrors = append(selectErrors, errs...)
+	}
+	span.SetAttributes(attribute.Int("selected", len(queue)), attribute.Int("skipped", skipped), attribute.Int("errors", len(selectErrors)))
+	span.End()
+	metrics.ObjectsSkipped(skipped)
+	for range selectErrors {
+		metrics.ObjectFailed()
+	}
+
+	// Objects that could not even be checked count towards the failure policy
+	var aborted error
+	if len(selectErrors) > 0 {
+		checked := len(queue) + len(selectErrors)
+		aborted = d.failures.check(len(selectErrors), checked, selectErrors[len(selectErrors)-1])
+	}
+
+	if len(queue) == 0 || aborted != nil {
+		return &DownloadResult{
+			TotalFiles:     len(selectErrors),
+			FailedFiles:    len(selectErrors),
+			SkippedFiles:   skipped,
+			Filtered:       filtered,
+			RemainingFiles: len(queue),
+			Duration:       time.Since(startTime),
+			Errors:         selectErrors,
+			Cancelled:      ctx.Err() != nil && len(selectErrors) > 0,
+			Aborted:        aborted,
+		}, nil
+	}
+
+	// Objects written to the same name would silently replace each other
+	if conflicts := d.conflicts(queue, sink); len(conflicts) > 0 {
+		return nil, conflictsError(conflicts)
 	}
 
-	// Create local directory if it doesn't exist
-	if err := os.MkdirAll(localDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create local directory: %w", err)
+	// Hash files as they are written so the manifest needs no second read
+	var sums *manifest
+	target := sink
+	if d.checksums != ChecksumNone {
+		sums = newManifest(d.checksums)
+		target = newChecksumSink(sink, sums)
 	}
 
+	hooks := newHookRunner(ctx, d.onFile, d.hookConcurrency, len(queue))
+
+	// Aborting cancels the remaining downloads only; the manifest and hooks still finish
+	jobCtx, abort := context.WithCancel(ctx)
+	defer abort()
+
 	// Create a channel for download jobs
-	jobs := make(chan providers.Object, len(objects))
-	results := make(chan providers.DownloadProgress, len(objects))
+	jobs := make(chan job, len(queue))
+	results := make(chan providers.DownloadProgress, len(queue))
 
 	// Start worker goroutines
 	var wg sync.WaitGroup
 	for i := 0; i < d.concurrency; i++ {
 		wg.Add(1)
-		go d.downloadWorker(ctx, &wg, jobs, results, prefix, localDir)
+		go d.downloadWorker(jobCtx, &wg, jobs, results, target, hooks)
 	}
 
 	// Send jobs to workers
 	go func() {
 		defer close(jobs)
-		for _, obj := range objects {
-			jobs <- obj
+		for _, j := range queue {
+			jobs <- j
 		}
 	}()
 
@@ -103,7 +330,13 @@ func (d *Downloader) DownloadFolder(ctx context.Context, prefix, localDir string
 	}()
 
 	// Process results and call progress callback
-	result := &DownloadResult{}
+	result := &DownloadResult{
+		TotalFiles:   len(selectErrors),
+		FailedFiles:  len(selectErrors),
+		SkippedFiles: skipped,
+		Filtered:     filtered,
+		Errors:       selectErrors,
+	}
 	for progress := range results {
 		result.TotalFiles++
 		result.TotalBytes += progress.TotalBytes
@@ -111,8 +344,18 @@ func (d *Downloader) DownloadFolder(ctx context.Context, prefix, localDir string
 		if progress.Error != nil {
 			result.FailedFiles++
 			result.Errors = append(result.Errors, progress.Error)
+			metrics.ObjectFailed()
+			d.logger.DebugContext(ctx, "download failed", "key", progress.Key, "error", progress.Error)
+
+			if result.Aborted == nil {
+				if result.Aborted = d.failures.check(result.FailedFiles, result.TotalFiles, progress.Error); result.Aborted != nil {
+					d.logger.WarnContext(ctx, "aborting download", "reason", result.Aborted)
+					abort()
+				}
+			}
 		} else {
 			result.SuccessfulFiles++
+			metrics.ObjectCompleted(progress.BytesDownloaded)
 		}
 
 		if progressCallback != nil {
@@ -120,82 +363,259 @@ func (d *Downloader) DownloadFolder(ctx context.Context, prefix, localDir string
 		}
 	}
 
+	if sums != nil {
+		if err := sums.writeTo(sink); err != nil {
+			result.Errors = append(result.Errors, err)
+		}
+	}
+
+	result.HookErrors = hooks.wait()
+	result.FailedHooks = len(result.HookErrors)
+	result.RemainingFiles = len(queue) - (result.TotalFiles - len(selectErrors))
+	result.Cancelled = ctx.Err() != nil && result.RemainingFiles > 0
+
 	result.Duration = time.Since(startTime)
 	return result, nil
 }
 
 // downloadWorker is a worker goroutine that downloads objects
-func (d *Downloader) downloadWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan providers.Object, results chan<- providers.DownloadProgress, prefix, localDir string) {
+func (d *Downloader) downloadWorker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan job, results chan<- providers.DownloadProgress, sink Sink, hooks *hookRunner) {
 	defer wg.Done()
 
-	for obj := range jobs {
+	for j := range jobs {
+		// Once cancelled or aborted, the remaining jobs are left undone rather than failed
+		if ctx.Err() != nil {
+			continue
+		}
+
+		obj := j.obj
 		progress := providers.DownloadProgress{
 			Key:        obj.Key,
 			TotalBytes: obj.Size,
 		}
 
-		// Download the object
-		if err := d.downloadObject(ctx, obj, prefix, localDir); err != nil {
+		// Download the object once the shared budget allows it, unless the download was cancelled
+		if err := d.budget.acquire(ctx); err != nil {
+			progress.Error = fmt.Errorf("%s: %w", obj.Key, err)
+		} else if file, err := d.downloadWithBudget(ctx, j.source, obj, sink); err != nil {
 			progress.Error = err
 		} else {
 			progress.BytesDownloaded = obj.Size
 			progress.Completed = true
+			if file != nil {
+				hooks.submit(*file)
+			}
+		}
+
+		// Downloads cut short by the cancellation count as remaining, not as errors
+		if progress.Error != nil && ctx.Err() != nil && interrupted(progress.Error) {
+			continue
 		}
 
 		results <- progress
 	}
 }
 
-// downloadObject downloads a single object
-func (d *Downloader) downloadObject(ctx context.Context, obj providers.Object, prefix, localDir string) error {
-	// Calculate local file path
-	relativePath := obj.Key
-	if prefix != "" && len(obj.Key) > len(prefix) {
-		relativePath = obj.Key[len(prefix):]
-		if relativePath[0] == '/' {
-			relativePath = relativePath[1:]
+// downloadWithBudget downloads an object in a span of its own while holding a slot of the budget
+func (d *Downloader) downloadWithBudget(ctx context.Context, source *Source, obj providers.Object, sink Sink) (file *DownloadedFile, err error) {
+	defer d.budget.release()
+	defer metrics.WorkerStarted()()
+
+	ctx, span := tracing.Start(ctx, "download",
+		attribute.String("key", obj.Key),
+		attribute.Int64("size", obj.Size),
+	)
+	defer func() {
+		if file != nil {
+			span.SetAttributes(attribute.String("name", file.Name), attribute.Int64("bytes", file.Size))
 		}
+		tracing.End(span, err)
+	}()
+
+	return d.downloadObject(ctx, source, obj, sink)
+}
+
+// downloadObject downloads a single object and describes the file it was written to.
+// Directory markers return no file.
+func (d *Downloader) downloadObject(ctx context.Context, source *Source, obj providers.Object, sink Sink) (*DownloadedFile, error) {
+	// Calculate the file name relative to the destination
+	name, err := d.mappedName(source, obj)
+	if err != nil {
+		return nil, err
 	}
-	
-	localPath := filepath.Join(localDir, relativePath)
-	
+	name = sinkName(sink, name)
+
 	// Skip if it's a directory (ends with /)
 	if obj.Key[len(obj.Key)-1] == '/' {
-		return os.MkdirAll(localPath, 0755)
+		return nil, sink.Mkdir(name)
 	}
 
-	// Create directory for the file if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory for %s: %w", localPath, err)
+	// Download the object, pinned to its version if one is known
+	var reader io.ReadCloser
+	if obj.VersionID != "" {
+		reader, err = source.Provider.DownloadObjectVersion(ctx, obj.Key, obj.VersionID)
+	} else {
+		reader, err = source.Provider.DownloadObject(ctx, obj.Key)
 	}
-
-	// Download the object
-	reader, err := d.provider.DownloadObject(ctx, obj.Key)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer reader.Close()
 
-	// Create local file
-	file, err := os.Create(localPath)
-	if err != nil {
-		return fmt.Errorf("failed to create local file %s: %w", localPath, err)
+	// Metadata is only fetched when a later stage needs it, and at most once.
+	// Providers that return it with the content need no extra request.
+	var info *providers.Object
+	if body, ok := reader.(*providers.ObjectBody); ok {
+		info = &body.Info
+	}
+	getInfo := func() (*providers.Object, error) {
+		if info == nil {
+			if info, err = objectInfo(ctx, source.Provider, obj); err != nil {
+				return nil, err
+			}
+		}
+		return info, nil
 	}
-	defer file.Close()
 
-	// Copy data
-	_, err = io.Copy(file, reader)
-	if err != nil {
-		return fmt.Errorf("failed to write data to %s: %w", localPath, err)
+	var src io.Reader = reader
+	if d.decrypter != nil {
+		objInfo, err := getInfo()
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := d.decrypter.Decrypt(ctx, objInfo.Metadata, src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", obj.Key, err)
+		}
+		defer plaintext.Close()
+		src = plaintext
 	}
 
-	if d.verbose {
-		fmt.Printf("Downloaded: %s -> %s\n", obj.Key, localPath)
+	// Unpack archives into a directory named after the key
+	if d.extract {
+		if format, c, base := detectArchive(name); format != archiveNone {
+			_, span := tracing.Start(ctx, "extract", attribute.String("name", base))
+			err := d.extractObject(obj, src, format, c, sink, base)
+			tracing.End(span, err)
+			if err != nil {
+				return nil, err
+			}
+			return d.downloadedFile(obj, sink, base, obj.Size), nil
+		}
+	}
+
+	if d.decompress {
+		// The extension is free to check; headers usually come with the content
+		c, decompressed := codecFromName(name)
+		if c == codecNone {
+			objInfo, err := getInfo()
+			if err != nil {
+				return nil, err
+			}
+			c = codecFromHeaders(objInfo.ContentEncoding, objInfo.ContentType)
+		}
+		if c != codecNone {
+			dec, err := newDecompressor(c, src)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress %s: %w", obj.Key, err)
+			}
+			defer dec.Close()
+			src = dec
+			name = decompressed
+		}
 	}
 
+	// Sinks that keep object properties get the full metadata along with the content
+	var written int64
+	if objectSink, ok := sink.(ObjectSink); ok {
+		objInfo, err := getInfo()
+		if err != nil {
+			return nil, err
+		}
+		if written, err = traceWrite(ctx, name, func() (int64, error) { return objectSink.WriteObject(name, src, *objInfo) }); err != nil {
+			return nil, err
+		}
+	} else if written, err = traceWrite(ctx, name, func() (int64, error) { return sink.WriteFile(name, src, obj.LastModified) }); err != nil {
+		return nil, err
+	}
+
+	d.logger.DebugContext(ctx, "downloaded", "key", obj.Key, "name", name, "bytes", written)
+
+	return d.downloadedFile(obj, sink, name, written), nil
+}
+
+// traceWrite runs a write to the sink in a span. The content is streamed from the
+// provider as it is written, so the span covers both transfer and storage.
+func traceWrite(ctx context.Context, name string, write func() (int64, error)) (int64, error) {
+	_, span := tracing.Start(ctx, "write", attribute.String("name", name))
+	written, err := write()
+	span.SetAttributes(attribute.Int64("bytes", written))
+	tracing.End(span, err)
+	return written, err
+}
+
+// downloadedFile describes a file written to the sink under name
+func (d *Downloader) downloadedFile(obj providers.Object, sink Sink, name string, size int64) *DownloadedFile {
+	file := &DownloadedFile{
+		Name:      name,
+		Key:       obj.Key,
+		VersionID: obj.VersionID,
+		Size:      size,
+		ETag:      obj.ETag,
+	}
+	if pather, ok := sink.(localPather); ok {
+		file.Path = pather.LocalPath(name)
+	}
+	return file
+}
+
+// relativeName names an object relative to prefix
+func relativeName(key, prefix string) string {
+	name := key
+	if prefix != "" && len(key) > len(prefix) {
+		name = key[len(prefix):]
+		if name[0] == '/' {
+			name = name[1:]
+		}
+	}
+	return name
+}
+
+// extractObject unpacks an archive object into the sink under dir
+func (d *Downloader) extractObject(obj providers.Object, src io.Reader, format archiveFormat, c codec, sink Sink, dir string) error {
+	if c != codecNone {
+		dec, err := newDecompressor(c, src)
+		if err != nil {
+			return fmt.Errorf("failed to decompress %s: %w", obj.Key, err)
+		}
+		defer dec.Close()
+		src = dec
+	}
+
+	var err error
+	switch format {
+	case archiveTar:
+		err = extractTar(src, sink, dir)
+	case archiveZip:
+		err = extractZip(src, sink, dir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", obj.Key, err)
+	}
+
+	d.logger.Debug("extracted", "key", obj.Key, "dir", dir)
+
 	return nil
 }
 
+// objectInfo fetches the full metadata of an object, at its pinned version if it has one
+func objectInfo(ctx context.Context, provider providers.Provider, obj providers.Object) (*providers.Object, error) {
+	if obj.VersionID != "" {
+		return provider.GetObjectVersionInfo(ctx, obj.Key, obj.VersionID)
+	}
+	return provider.GetObjectInfo(ctx, obj.Key)
+}
+
 // Close cleans up resources
 func (d *Downloader) Close() error {
 	return d.provider.Close()
@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kinds of download failure, matched with errors.Is
+var (
+	// ErrPartialFailure means some objects could not be downloaded
+	ErrPartialFailure = errors.New("some objects failed")
+	// ErrCancelled means the download was interrupted before every object was handled
+	ErrCancelled = errors.New("cancelled")
+	// ErrAborted means a FailurePolicy stopped the download before every object was handled
+	ErrAborted = errors.New("download aborted")
+	// ErrVerificationFailed means downloaded files do not match the objects they came from
+	ErrVerificationFailed = errors.New("verification failed")
+	// ErrConflict means several objects would be written to the same name, so nothing was downloaded
+	ErrConflict = errors.New("objects map to the same name")
+)
+
+// maxConflictsReported is how many conflicting names conflictsError lists
+const maxConflictsReported = 5
+
+// conflictsError describes the names that several objects would be written to
+func conflictsError(conflicts []Conflict) error {
+	var names []string
+	for _, c := range conflicts[:min(len(conflicts), maxConflictsReported)] {
+		names = append(names, fmt.Sprintf("%s <- %s", c.Name, strings.Join(c.Keys, ", ")))
+	}
+	if len(conflicts) > maxConflictsReported {
+		names = append(names, fmt.Sprintf("and %d more", len(conflicts)-maxConflictsReported))
+	}
+	return fmt.Errorf("%w: %s", ErrConflict, strings.Join(names, "; "))
+}
+
+// Err reports how a download failed: ErrCancelled if it was interrupted, the abort
+// reason wrapping ErrAborted if the failure policy stopped it, ErrPartialFailure if
+// some objects failed, or nil. Failed hooks are not download failures.
+func (r *DownloadResult) Err() error {
+	switch {
+	case r.Cancelled:
+		return fmt.Errorf("download %w after %d of %d files", ErrCancelled, r.SuccessfulFiles, r.TotalFiles+r.RemainingFiles)
+	case r.Aborted != nil:
+		return r.Aborted
+	case len(r.Errors) > 0:
+		return fmt.Errorf("download completed with %d errors: %w", len(r.Errors), ErrPartialFailure)
+	}
+	return nil
+}
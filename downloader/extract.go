@@ -0,0 +1,123 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// archiveFormat identifies an archive container
+type archiveFormat string
+
+const (
+	archiveNone archiveFormat = ""
+	archiveTar  archiveFormat = "tar"
+	archiveZip  archiveFormat = "zip"
+)
+
+// detectArchive recognizes archives by extension. It returns the container format,
+// the compression wrapped around it, and the name with the archive extensions removed.
+func detectArchive(name string) (archiveFormat, codec, string) {
+	if strings.HasSuffix(name, ".zip") {
+		return archiveZip, codecNone, strings.TrimSuffix(name, ".zip")
+	}
+
+	c, inner := codecFromName(name)
+	if strings.HasSuffix(inner, ".tar") && len(inner) > len(".tar") {
+		return archiveTar, c, strings.TrimSuffix(inner, ".tar")
+	}
+
+	return archiveNone, codecNone, name
+}
+
+// extractTar unpacks a tar stream into the sink under dir.
+// Only directories and regular files are extracted; links and special files are skipped.
+func extractTar(r io.Reader, sink Sink, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+
+		name, err := safeJoin(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := sink.Mkdir(name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if _, err := sink.WriteFile(name, tr, hdr.ModTime); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractZip unpacks a zip archive into the sink under dir.
+// Zip needs random access, so the archive is first spooled to a temporary file.
+func extractZip(r io.Reader, sink Sink, dir string) error {
+	tmp, size, err := spool(r)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+
+	for _, f := range zr.File {
+		name, err := safeJoin(dir, f.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if err := sink.Mkdir(name); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read %s from zip archive: %w", f.Name, err)
+			}
+			_, err = sink.WriteFile(name, rc, f.Modified)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// safeJoin joins an archive entry name onto dir, rejecting names that would escape it
+func safeJoin(dir, name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, `\`) {
+		return "", fmt.Errorf("unsafe path in archive: %q", name)
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("unsafe path in archive: %q", name)
+	}
+
+	return path.Join(dir, cleaned), nil
+}
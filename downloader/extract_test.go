@@ -0,0 +1,191 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+// memorySink records the files and directories written to it
+type memorySink struct {
+	files map[string]string
+	dirs  []string
+}
+
+func newMemorySink() *memorySink {
+	return &memorySink{files: make(map[string]string)}
+}
+
+func (m *memorySink) WriteFile(name string, r io.Reader, modTime time.Time) (int64, error) {
+	content, err := io.ReadAll(r)
+	m.files[name] = string(content)
+	return int64(len(content)), err
+}
+
+func (m *memorySink) Mkdir(name string) error {
+	m.dirs = append(m.dirs, name)
+	return nil
+}
+
+func (m *memorySink) Close() error {
+	return nil
+}
+
+func (m *memorySink) names() []string {
+	var names []string
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSafeJoin(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "file.txt", want: "out/file.txt"},
+		{name: "a/b/c.txt", want: "out/a/b/c.txt"},
+		{name: "./a//b.txt", want: "out/a/b.txt"},
+		{name: "a/../b.txt", want: "out/b.txt"},
+		{name: "a/", want: "out/a"},
+		{name: "../x", wantErr: true},
+		{name: "..", wantErr: true},
+		{name: "a/../../x", wantErr: true},
+		{name: "a/b/../../../x", wantErr: true},
+		{name: "/etc/passwd", wantErr: true},
+		{name: "//server/share", wantErr: true},
+		{name: `..\x`, wantErr: true},
+		{name: `a\b`, wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := safeJoin("out", tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("safeJoin(%q) = %q, want an error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("safeJoin(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func tarArchive(t *testing.T, headers ...*tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, hdr := range headers {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(hdr.Name))
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			w.Write([]byte(hdr.Name))
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractTarRejectsEscapingEntries(t *testing.T) {
+	for _, name := range []string{"../x", "a/../../x", "/etc/passwd"} {
+		archive := tarArchive(t, &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644})
+		sink := newMemorySink()
+		if err := extractTar(bytes.NewReader(archive), sink, "out"); err == nil {
+			t.Errorf("entry %q: expected an error", name)
+		}
+		if len(sink.files) != 0 {
+			t.Errorf("entry %q: wrote %v", name, sink.names())
+		}
+	}
+}
+
+func TestExtractTarSkipsLinks(t *testing.T) {
+	archive := tarArchive(t,
+		&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755},
+		&tar.Header{Name: "dir/file.txt", Typeflag: tar.TypeReg, Mode: 0o644},
+		&tar.Header{Name: "dir/symlink", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		&tar.Header{Name: "dir/hardlink", Typeflag: tar.TypeLink, Linkname: "../../etc/passwd"},
+	)
+
+	sink := newMemorySink()
+	if err := extractTar(bytes.NewReader(archive), sink, "out"); err != nil {
+		t.Fatal(err)
+	}
+	if names := sink.names(); len(names) != 1 || names[0] != "out/dir/file.txt" {
+		t.Errorf("extracted files %v, want only out/dir/file.txt", names)
+	}
+	if len(sink.dirs) != 1 || sink.dirs[0] != "out/dir" {
+		t.Errorf("extracted directories %v, want out/dir", sink.dirs)
+	}
+}
+
+// zipEntry is a file to put in a test zip archive
+type zipEntry struct {
+	name    string
+	mode    os.FileMode
+	content string
+}
+
+// extractZipEntries builds a zip archive of the entries and extracts it into a memory sink
+func extractZipEntries(t *testing.T, entries ...zipEntry) (*memorySink, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Store}
+		hdr.SetMode(e.mode)
+		f, err := w.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(e.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := newMemorySink()
+	return sink, extractZip(&buf, sink, "out")
+}
+
+func TestExtractZipRejectsEscapingEntries(t *testing.T) {
+	for _, name := range []string{"../x", "a/../../x", "/etc/passwd"} {
+		sink, err := extractZipEntries(t, zipEntry{name: name, mode: 0o644, content: "x"})
+		if err == nil {
+			t.Errorf("entry %q: expected an error", name)
+		}
+		if len(sink.files) != 0 {
+			t.Errorf("entry %q: wrote %v", name, sink.names())
+		}
+	}
+}
+
+func TestExtractZipSkipsLinks(t *testing.T) {
+	sink, err := extractZipEntries(t,
+		zipEntry{name: "file.txt", mode: 0o644, content: "content"},
+		zipEntry{name: "symlink", mode: os.ModeSymlink | 0o777, content: "/etc/passwd"},
+		zipEntry{name: "up", mode: os.ModeSymlink | 0o777, content: "../../etc"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := sink.names(); len(names) != 1 || sink.files["out/file.txt"] != "content" {
+		t.Errorf("extracted %v, want only out/file.txt", names)
+	}
+}
@@ -0,0 +1,44 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"download-file-from-bucket/providers"
+)
+
+// minErrorRateSample is how many objects must have been handled before the error rate is checked,
+// so a single early failure does not abort a large download
+const minErrorRateSample = 20
+
+// FailurePolicy decides when failures abort a download instead of leaving it to handle every object
+type FailurePolicy struct {
+	// FailFast aborts on the first error that retrying would not fix, such as access denied.
+	// Throttling and network errors do not trigger it.
+	FailFast bool
+	// MaxErrors aborts once this many objects have failed; zero means no limit
+	MaxErrors int
+	// MaxErrorRate aborts once more than this fraction of the handled objects have failed; zero means no limit
+	MaxErrorRate float64
+}
+
+// check returns why the download should be aborted after err, now that failed of handled objects
+// have failed, or nil to carry on
+func (p FailurePolicy) check(failed, handled int, err error) error {
+	switch {
+	case p.FailFast && !providers.IsTransient(err):
+		return fmt.Errorf("%w after a permanent error: %w", ErrAborted, err)
+	case p.MaxErrors > 0 && failed >= p.MaxErrors:
+		return fmt.Errorf("%w after %d errors; last error: %w", ErrAborted, failed, err)
+	case p.MaxErrorRate > 0 && handled >= minErrorRateSample && float64(failed) > p.MaxErrorRate*float64(handled):
+		return fmt.Errorf("%w: %d of %d objects failed, more than %g%%; last error: %w",
+			ErrAborted, failed, handled, p.MaxErrorRate*100, err)
+	}
+	return nil
+}
+
+// interrupted reports whether err only means the download was stopped before the object was done
+func interrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
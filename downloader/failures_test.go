@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"download-file-from-bucket/providers"
+)
+
+func TestFailurePolicyCheck(t *testing.T) {
+	denied := fmt.Errorf("failed to download a.txt: %w", providers.ErrAccessDenied)
+	throttled := fmt.Errorf("failed to download a.txt: %w", providers.ErrThrottled)
+	network := fmt.Errorf("failed to download a.txt: %w", providers.ErrNetwork)
+
+	tests := []struct {
+		name    string
+		policy  FailurePolicy
+		failed  int
+		handled int
+		err     error
+		abort   bool
+	}{
+		{"no limits", FailurePolicy{}, 100, 100, denied, false},
+
+		{"fail fast on a permanent error", FailurePolicy{FailFast: true}, 1, 1, denied, true},
+		{"fail fast ignores throttling", FailurePolicy{FailFast: true}, 1, 1, throttled, false},
+		{"fail fast ignores network errors", FailurePolicy{FailFast: true}, 1, 1, network, false},
+
+		{"below max errors", FailurePolicy{MaxErrors: 3}, 2, 10, throttled, false},
+		{"at max errors", FailurePolicy{MaxErrors: 3}, 3, 10, throttled, true},
+
+		{"rate before the sample", FailurePolicy{MaxErrorRate: 0.05}, 5, minErrorRateSample - 1, network, false},
+		{"rate at the limit", FailurePolicy{MaxErrorRate: 0.05}, 1, minErrorRateSample, network, false},
+		{"rate above the limit", FailurePolicy{MaxErrorRate: 0.05}, 2, minErrorRateSample, network, true},
+		{"zero rate is no limit", FailurePolicy{MaxErrorRate: 0}, 20, minErrorRateSample, network, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.policy.check(tt.failed, tt.handled, tt.err)
+		if (err != nil) != tt.abort {
+			t.Errorf("%s: check = %v, want abort %v", tt.name, err, tt.abort)
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrAborted) {
+			t.Errorf("%s: %v does not wrap ErrAborted", tt.name, err)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: %v does not wrap the last error", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"download-file-from-bucket/providers"
+)
+
+// fakeProvider keeps objects in memory. Keys listed in failures fail to download with that error.
+// Downloads return the object's properties with its content, as the S3 provider does.
+// Uploads keep their options, which are returned as the object's properties.
+type fakeProvider struct {
+	mu        sync.Mutex
+	objects   map[string][]byte
+	failures  map[string]error
+	encodings map[string]string
+	uploads   map[string]providers.UploadOptions
+	// infoRequests counts the metadata requests made separately from downloads
+	infoRequests int
+}
+
+func newFakeProvider(objects map[string]string) *fakeProvider {
+	f := &fakeProvider{
+		objects:   make(map[string][]byte),
+		failures:  make(map[string]error),
+		encodings: make(map[string]string),
+		uploads:   make(map[string]providers.UploadOptions),
+	}
+	for key, content := range objects {
+		f.objects[key] = []byte(content)
+	}
+	return f
+}
+
+func (f *fakeProvider) ListObjects(ctx context.Context, prefix string) ([]providers.Object, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var objects []providers.Object
+	for key, content := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, providers.Object{Key: key, Size: int64(len(content))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (f *fakeProvider) ListObjectVersions(ctx context.Context, prefix string) ([]providers.Object, error) {
+	return f.ListObjects(ctx, prefix)
+}
+
+func (f *fakeProvider) DownloadObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err, ok := f.failures[key]; ok {
+		return nil, err
+	}
+	content, ok := f.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, providers.ErrNotFound)
+	}
+	return &providers.ObjectBody{ReadCloser: io.NopCloser(bytes.NewReader(content)), Info: f.info(key)}, nil
+}
+
+func (f *fakeProvider) DownloadObjectVersion(ctx context.Context, key, versionID string) (io.ReadCloser, error) {
+	return f.DownloadObject(ctx, key)
+}
+
+func (f *fakeProvider) GetObjectInfo(ctx context.Context, key string) (*providers.Object, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.infoRequests++
+	if _, ok := f.objects[key]; !ok {
+		return nil, fmt.Errorf("%s: %w", key, providers.ErrNotFound)
+	}
+	info := f.info(key)
+	return &info, nil
+}
+
+func (f *fakeProvider) info(key string) providers.Object {
+	obj := providers.Object{Key: key, Size: int64(len(f.objects[key])), ContentEncoding: f.encodings[key]}
+	if opts, ok := f.uploads[key]; ok {
+		obj.ContentType = opts.ContentType
+		obj.ContentEncoding = opts.ContentEncoding
+		obj.Metadata = opts.Metadata
+	}
+	return obj
+}
+
+func (f *fakeProvider) GetObjectVersionInfo(ctx context.Context, key, versionID string) (*providers.Object, error) {
+	return f.GetObjectInfo(ctx, key)
+}
+
+func (f *fakeProvider) GetObjectTags(ctx context.Context, key, versionID string) (map[string]string, error) {
+	return nil, nil
+}
+
+func (f *fakeProvider) RestoreObject(ctx context.Context, key string, days int64, tier string) error {
+	return nil
+}
+
+func (f *fakeProvider) UploadObject(ctx context.Context, key string, r io.Reader, opts providers.UploadOptions) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = content
+	f.uploads[key] = opts
+	return nil
+}
+
+func (f *fakeProvider) Close() error {
+	return nil
+}
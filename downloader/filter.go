@@ -0,0 +1,172 @@
+package downloader
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"download-file-from-bucket/providers"
+)
+
+// Filter selects objects by their properties. The zero value selects every object.
+type Filter struct {
+	// NewerThan keeps objects modified after this time
+	NewerThan time.Time
+	// OlderThan keeps objects modified before this time
+	OlderThan time.Time
+	// MinSize keeps objects of at least this many bytes
+	MinSize int64
+	// MaxSize keeps objects of at most this many bytes; zero means no limit
+	MaxSize int64
+	// Metadata keeps objects whose user metadata has all of these values; keys are case-insensitive
+	Metadata map[string]string
+	// Tags keeps objects that have all of these tags
+	Tags map[string]string
+}
+
+// matchesListing checks the properties returned when listing objects
+func (f Filter) matchesListing(obj providers.Object) bool {
+	if !f.NewerThan.IsZero() && !obj.LastModified.After(f.NewerThan) {
+		return false
+	}
+	if !f.OlderThan.IsZero() && !obj.LastModified.Before(f.OlderThan) {
+		return false
+	}
+	if obj.Size < f.MinSize {
+		return false
+	}
+	if f.MaxSize > 0 && obj.Size > f.MaxSize {
+		return false
+	}
+	return true
+}
+
+// needsRequests reports whether checking the filter takes a request per object
+func (f Filter) needsRequests() bool {
+	return len(f.Metadata) > 0 || len(f.Tags) > 0
+}
+
+// matchesDetails checks user metadata and tags, fetching each only if the filter uses it
+func (f Filter) matchesDetails(ctx context.Context, provider providers.Provider, obj providers.Object) (bool, error) {
+	if len(f.Metadata) > 0 {
+		info, err := objectInfo(ctx, provider, obj)
+		if err != nil {
+			return false, err
+		}
+
+		// HTTP headers are case-insensitive, and providers differ in how they case metadata keys
+		metadata := make(map[string]string, len(info.Metadata))
+		for k, v := range info.Metadata {
+			metadata[strings.ToLower(k)] = v
+		}
+		for k, v := range f.Metadata {
+			if value, ok := metadata[strings.ToLower(k)]; !ok || value != v {
+				return false, nil
+			}
+		}
+	}
+
+	if len(f.Tags) > 0 {
+		tags, err := provider.GetObjectTags(ctx, obj.Key, obj.VersionID)
+		if err != nil {
+			return false, err
+		}
+		for k, v := range f.Tags {
+			if value, ok := tags[k]; !ok || value != v {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// skippedObject is an object left out of a download, and why
+type skippedObject struct {
+	obj    providers.Object
+	reason string
+	// filtered is set if the object stays left out until it changes; archived objects are
+	// not filtered, as restoring them does not change them
+	filtered bool
+}
+
+// skipReason says why an object is left out by the properties known from listing it, or returns
+// an empty string if it is kept
+func (d *Downloader) skipReason(source *Source, obj providers.Object) string {
+	switch {
+	case d.skipsArchived(obj):
+		return "archived in " + obj.StorageClass
+	case !source.Pattern.Match(relativeName(obj.Key, source.Prefix)):
+		return "does not match the pattern"
+	case !d.filter.matchesListing(obj):
+		return "filtered by date or size"
+	case d.paths != nil && !d.paths.keeps(obj, source.Prefix):
+		return "dropped by path mapping"
+	}
+	return ""
+}
+
+// skipsArchived reports whether obj is skipped until it is restored
+func (d *Downloader) skipsArchived(obj providers.Object) bool {
+	return d.skipArchived && obj.NeedsRestore()
+}
+
+// selectObjects drops the objects of a source that should not be downloaded.
+// It returns the selected objects, the skipped ones, and the errors of objects that could not be checked.
+func (d *Downloader) selectObjects(ctx context.Context, source *Source, objects []providers.Object) ([]providers.Object, []skippedObject, []error) {
+	selected := make([]providers.Object, 0, len(objects))
+	var skipped []skippedObject
+	for _, obj := range objects {
+		if reason := d.skipReason(source, obj); reason != "" {
+			d.logger.DebugContext(ctx, "skipping object", "key", obj.Key, "reason", reason)
+			skipped = append(skipped, skippedObject{obj: obj, reason: reason, filtered: !d.skipsArchived(obj)})
+			continue
+		}
+		selected = append(selected, obj)
+	}
+
+	if !d.filter.needsRequests() {
+		return selected, skipped, nil
+	}
+
+	// Metadata and tags need a request per object, made concurrently; directory markers have neither
+	keep := make([]bool, len(selected))
+	errs := make([]error, len(selected))
+	indexes := make(chan int, len(selected))
+	for i := range selected {
+		indexes <- i
+	}
+	close(indexes)
+
+	var wg sync.WaitGroup
+	for w := 0; w < d.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				obj := selected[i]
+				if strings.HasSuffix(obj.Key, "/") {
+					continue
+				}
+				keep[i], errs[i] = d.filter.matchesDetails(ctx, source.Provider, obj)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var checked []providers.Object
+	var failed []error
+	for i, obj := range selected {
+		switch {
+		case errs[i] != nil:
+			failed = append(failed, errs[i])
+		case keep[i]:
+			checked = append(checked, obj)
+		default:
+			skipped = append(skipped, skippedObject{obj: obj, reason: "filtered by metadata or tags", filtered: true})
+		}
+	}
+
+	return checked, skipped, failed
+}
@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DownloadedFile describes a file that was written to the sink
+type DownloadedFile struct {
+	// Name is the slash-separated name of the file in the sink
+	Name string
+	// Path is the location of the file on disk, or empty if the sink does not write to disk
+	Path string
+	// Key and VersionID identify the object the file came from
+	Key       string
+	VersionID string
+	// Size is the number of bytes written, which differs from the object size for decrypted or decompressed files
+	Size int64
+	ETag string
+}
+
+// FileHook is called after each object is downloaded successfully
+type FileHook func(ctx context.Context, file DownloadedFile) error
+
+// localPather is implemented by sinks that write files to disk
+type localPather interface {
+	// LocalPath returns where the file with the given name is stored
+	LocalPath(name string) string
+}
+
+// hookRunner calls a hook on its own bounded pool of workers, so slow hooks do not hold up downloads
+type hookRunner struct {
+	queue chan DownloadedFile
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	errs  []error
+}
+
+// newHookRunner starts concurrency workers calling hook, or returns nil if there is no hook
+func newHookRunner(ctx context.Context, hook FileHook, concurrency, capacity int) *hookRunner {
+	if hook == nil {
+		return nil
+	}
+
+	r := &hookRunner{queue: make(chan DownloadedFile, capacity)}
+	for i := 0; i < concurrency; i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			for file := range r.queue {
+				if err := hook(ctx, file); err != nil {
+					r.mu.Lock()
+					r.errs = append(r.errs, fmt.Errorf("hook failed for %s: %w", file.Key, err))
+					r.mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	return r
+}
+
+// submit queues a file for the hook
+func (r *hookRunner) submit(file DownloadedFile) {
+	if r != nil {
+		r.queue <- file
+	}
+}
+
+// wait waits for every queued hook to finish and returns their errors
+func (r *hookRunner) wait() []error {
+	if r == nil {
+		return nil
+	}
+
+	close(r.queue)
+	r.wg.Wait()
+	return r.errs
+}
@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestFileHookFailuresAreReported(t *testing.T) {
+	provider := newFakeProvider(map[string]string{
+		"data/a.txt": "a",
+		"data/b.txt": "bb",
+		"data/c.txt": "ccc",
+	})
+
+	var mu sync.Mutex
+	var files []DownloadedFile
+	hook := func(ctx context.Context, file DownloadedFile) error {
+		mu.Lock()
+		files = append(files, file)
+		mu.Unlock()
+		if file.Name == "b.txt" {
+			return errors.New("hook broke")
+		}
+		return nil
+	}
+
+	dir := t.TempDir()
+	d := NewDownloader(provider, Options{OnFile: hook, HookConcurrency: 2})
+	result, err := d.DownloadFolder(context.Background(), "data/", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed hook does not fail the download of its file
+	if result.SuccessfulFiles != 3 || result.FailedFiles != 0 {
+		t.Errorf("downloaded %d files with %d failures, want 3 and 0", result.SuccessfulFiles, result.FailedFiles)
+	}
+	if result.FailedHooks != 1 || len(result.HookErrors) != 1 {
+		t.Fatalf("failed hooks = %d, errors = %v, want one", result.FailedHooks, result.HookErrors)
+	}
+	if err := result.Err(); err != nil {
+		t.Errorf("Err = %v, want nil for hook failures", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	if len(files) != 3 {
+		t.Fatalf("hook called for %d files, want 3", len(files))
+	}
+	if got, want := files[1].Path, filepath.Join(dir, "b.txt"); got != want {
+		t.Errorf("Path = %q, want %q", got, want)
+	}
+	if files[1].Key != "data/b.txt" || files[1].Size != 2 {
+		t.Errorf("file = %+v", files[1])
+	}
+}
+
+func TestFileHookHasNoPathInArchives(t *testing.T) {
+	provider := newFakeProvider(map[string]string{"data/a.txt": "a"})
+
+	var got []DownloadedFile
+	hook := func(ctx context.Context, file DownloadedFile) error {
+		got = append(got, file)
+		return nil
+	}
+
+	sink := newMemorySink()
+	d := NewDownloader(provider, Options{OnFile: hook, HookConcurrency: 1})
+	if _, err := d.DownloadFolderTo(context.Background(), "data/", sink, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "a.txt" || got[0].Path != "" {
+		t.Errorf("hook files = %+v, want a.txt without a path", got)
+	}
+}
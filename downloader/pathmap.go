@@ -0,0 +1,106 @@
+package downloader
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"download-file-from-bucket/providers"
+)
+
+// Rewrite replaces the part of a file name matched by a regular expression
+type Rewrite struct {
+	Pattern *regexp.Regexp
+	// Replacement may refer to capture groups as $1 or ${name}
+	Replacement string
+}
+
+// ParseRewrite parses a rewrite rule written as "REGEX -> REPLACEMENT"
+func ParseRewrite(rule string) (Rewrite, error) {
+	pattern, replacement, ok := strings.Cut(rule, "->")
+	if !ok {
+		return Rewrite{}, fmt.Errorf("invalid rewrite rule %q: expected REGEX -> REPLACEMENT", rule)
+	}
+
+	re, err := regexp.Compile(strings.TrimSpace(pattern))
+	if err != nil {
+		return Rewrite{}, fmt.Errorf("invalid rewrite rule %q: %w", rule, err)
+	}
+
+	return Rewrite{Pattern: re, Replacement: strings.TrimSpace(replacement)}, nil
+}
+
+// PathMapper changes where objects are written in the sink. The steps run in order:
+// components are stripped, the first matching rewrite rule is applied, the name is
+// flattened, and finally the template, if any, builds the name.
+type PathMapper struct {
+	// StripComponents removes this many leading directories; files with no more are skipped
+	StripComponents int
+	// Rewrites are tried in order and the first one that matches is applied
+	Rewrites []Rewrite
+	// Flatten drops all directories, keeping only the base name
+	Flatten bool
+	// Template builds the name from placeholders: {path} is the name after the steps above,
+	// {key} the full object key, {basename} its last element, {etag} the ETag without quotes,
+	// and {yyyy}, {mm} and {dd} the UTC date the object was last modified
+	Template string
+}
+
+// Map returns the name of a file in the sink, or an empty name if the file should be skipped.
+// name is the file name relative to the download prefix.
+func (m *PathMapper) Map(name string, obj providers.Object) (string, error) {
+	if m == nil {
+		return name, nil
+	}
+
+	if m.StripComponents > 0 {
+		parts := strings.SplitN(name, "/", m.StripComponents+1)
+		if len(parts) <= m.StripComponents {
+			return "", nil
+		}
+		name = parts[m.StripComponents]
+	}
+
+	for _, rewrite := range m.Rewrites {
+		if rewrite.Pattern.MatchString(name) {
+			name = rewrite.Pattern.ReplaceAllString(name, rewrite.Replacement)
+			break
+		}
+	}
+
+	if m.Flatten {
+		name = path.Base(name)
+	}
+
+	if m.Template != "" {
+		modified := obj.LastModified.UTC()
+		name = strings.NewReplacer(
+			"{path}", name,
+			"{key}", obj.Key,
+			"{basename}", path.Base(obj.Key),
+			"{etag}", strings.Trim(obj.ETag, `"`),
+			"{yyyy}", modified.Format("2006"),
+			"{mm}", modified.Format("01"),
+			"{dd}", modified.Format("02"),
+		).Replace(m.Template)
+	}
+
+	// Rules and templates are user input, so the result must stay inside the sink
+	cleaned := path.Clean(strings.TrimPrefix(name, "/"))
+	if name == "" || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("path mapping turned %s into unusable name %q", obj.Key, name)
+	}
+
+	return cleaned, nil
+}
+
+// keeps reports whether an object is written at all: mapped layouts hold only files,
+// and files are dropped when stripping components leaves nothing
+func (m *PathMapper) keeps(obj providers.Object, prefix string) bool {
+	if strings.HasSuffix(obj.Key, "/") {
+		return false
+	}
+	name, err := m.Map(relativeName(obj.Key, prefix), obj)
+	return err != nil || name != ""
+}
@@ -0,0 +1,149 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"download-file-from-bucket/providers"
+)
+
+func mustRewrite(t *testing.T, rule string) Rewrite {
+	t.Helper()
+	rewrite, err := ParseRewrite(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rewrite
+}
+
+func TestPathMapperMap(t *testing.T) {
+	obj := providers.Object{
+		Key:          "data/logs/2024/app.log",
+		ETag:         `"abc123"`,
+		LastModified: time.Date(2024, 3, 9, 23, 30, 0, 0, time.FixedZone("", -2*60*60)),
+	}
+	const name = "logs/2024/app.log"
+
+	tests := []struct {
+		desc   string
+		mapper *PathMapper
+		want   string
+	}{
+		{"nil mapper", nil, name},
+		{"strip components", &PathMapper{StripComponents: 1}, "2024/app.log"},
+		{"strip every directory", &PathMapper{StripComponents: 2}, "app.log"},
+		{"strip past the file", &PathMapper{StripComponents: 3}, ""},
+		{"first matching rewrite", &PathMapper{Rewrites: []Rewrite{
+			mustRewrite(t, `^nothing/ -> x/`),
+			mustRewrite(t, `^logs/(\d+)/ -> year=$1/`),
+			mustRewrite(t, `^logs/ -> other/`),
+		}}, "year=2024/app.log"},
+		{"flatten", &PathMapper{Flatten: true}, "app.log"},
+		{"rewrite after strip, then flatten", &PathMapper{
+			StripComponents: 1,
+			Rewrites:        []Rewrite{mustRewrite(t, `\.log$ -> .txt`)},
+			Flatten:         true,
+		}, "app.txt"},
+		{"template", &PathMapper{Template: "{yyyy}/{mm}/{dd}/{etag}-{basename}"}, "2024/03/10/abc123-app.log"},
+		{"template with path and key", &PathMapper{StripComponents: 1, Template: "{path}|{key}"}, "2024/app.log|data/logs/2024/app.log"},
+		{"leading slash", &PathMapper{Template: "/{basename}"}, "app.log"},
+		{"cleaned", &PathMapper{Template: "a//./{basename}"}, "a/app.log"},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.mapper.Map(name, obj)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Map = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestPathMapperMapRejectsEscapes(t *testing.T) {
+	obj := providers.Object{Key: "data/app.log"}
+
+	for _, mapper := range []*PathMapper{
+		{Rewrites: []Rewrite{mustRewrite(t, `^ -> ../`)}},
+		{Rewrites: []Rewrite{mustRewrite(t, `.* -> `)}},
+		{Template: "../{path}"},
+		{Template: "{path}/../.."},
+		{Template: "."},
+	} {
+		if got, err := mapper.Map("app.log", obj); err == nil {
+			t.Errorf("%+v: mapped to %q, want an error", mapper, got)
+		}
+	}
+}
+
+func TestParseRewrite(t *testing.T) {
+	rewrite, err := ParseRewrite(`  ^a/(.*)  ->  b/$1  `)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewrite.Pattern.String() != "^a/(.*)" || rewrite.Replacement != "b/$1" {
+		t.Errorf("got %q -> %q", rewrite.Pattern, rewrite.Replacement)
+	}
+
+	for _, rule := range []string{"no arrow", "[a -> b"} {
+		if _, err := ParseRewrite(rule); err == nil {
+			t.Errorf("ParseRewrite(%q): expected an error", rule)
+		}
+	}
+}
+
+func TestDownloadFailsWhenFlattenedNamesCollide(t *testing.T) {
+	provider := newFakeProvider(map[string]string{
+		"data/x/a.txt": "x",
+		"data/y/a.txt": "y",
+		"data/y/b.txt": "b",
+	})
+
+	dir := t.TempDir()
+	d := NewDownloader(provider, Options{Paths: &PathMapper{Flatten: true}})
+	_, err := d.DownloadFolder(context.Background(), "data/", dir, nil)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("err = %v, want ErrConflict", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("wrote %d files, want none", len(entries))
+	}
+}
+
+func TestDownloadSourcesRejectsCollisions(t *testing.T) {
+	provider := newFakeProvider(map[string]string{
+		"eu/app.log":  "eu",
+		"us/app.log":  "us",
+		"us/only.log": "us only",
+	})
+	sources := []Source{{Prefix: "eu/"}, {Prefix: "us/"}}
+	d := NewDownloader(provider, Options{})
+
+	dir := t.TempDir()
+	if _, err := d.DownloadSources(context.Background(), sources, NewDirSink(dir), nil); !errors.Is(err, ErrConflict) {
+		t.Fatalf("merged sources: err = %v, want ErrConflict", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("merged sources wrote %d files, want none", len(entries))
+	}
+
+	// Each source in its own directory has nothing to collide with
+	sources[0].Dir, sources[1].Dir = "eu", "us"
+	result, err := d.DownloadSources(context.Background(), sources, NewDirSink(dir), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessfulFiles != 3 {
+		t.Errorf("downloaded %d files, want 3: %v", result.SuccessfulFiles, result.Errors)
+	}
+}
@@ -0,0 +1,237 @@
+package downloader
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"download-file-from-bucket/providers"
+	"download-file-from-bucket/tracing"
+)
+
+// Action is what a download would do with an object
+type Action string
+
+const (
+	// ActionDownload writes a file that is not in the sink yet
+	ActionDownload Action = "download"
+	// ActionOverwrite replaces a file that is already in the sink
+	ActionOverwrite Action = "overwrite"
+	// ActionSkip leaves the object out of the download
+	ActionSkip Action = "skip"
+)
+
+// PlannedFile is an object found for a download and what would be done with it
+type PlannedFile struct {
+	Key       string
+	VersionID string
+	Size      int64
+	Action    Action
+	// Name is where the object would be written in the sink; extracted archives name a
+	// directory, ending in "/". It is empty for skipped objects.
+	Name string
+	// Unsanitized is the name before it was made safe for the sink, set only if that changed it
+	Unsanitized string
+	// ExistingSize is the size of the file that would be overwritten
+	ExistingSize int64
+	// Reason says why a skipped object is left out
+	Reason string
+}
+
+// Conflict is a name in the sink that more than one object would be written to,
+// or that one object would write as a file and others as a directory
+type Conflict struct {
+	Name string
+	Keys []string
+}
+
+// Plan describes what a download would do, without having downloaded or written anything
+type Plan struct {
+	Files     []PlannedFile
+	Conflicts []Conflict
+	// Errors holds the objects that could not be checked or named
+	Errors []error
+
+	DownloadFiles  int
+	OverwriteFiles int
+	SkippedFiles   int
+	// SanitizedFiles counts the files whose names were changed to be safe for the sink
+	SanitizedFiles int
+	DownloadBytes  int64
+	OverwriteBytes int64
+}
+
+// PlanSources lists, selects and names the objects of several sources the way DownloadSources
+// would, but downloads and writes nothing. Sinks that implement ExistingSink are listed to find
+// the files that would be overwritten.
+func (d *Downloader) PlanSources(ctx context.Context, sources []Source, sink Sink) (*Plan, error) {
+	batches, _, err := d.listSources(ctx, sources)
+	if err != nil {
+		return nil, err
+	}
+	return d.plan(ctx, batches, sink)
+}
+
+// PlanSourceObjects plans the download of the given objects of a source, like DownloadSourceObjects
+func (d *Downloader) PlanSourceObjects(ctx context.Context, source Source, objects []providers.Object, sink Sink) (*Plan, error) {
+	if source.Provider == nil {
+		source.Provider = d.provider
+	}
+	return d.plan(ctx, []batch{{source: &source, objects: objects}}, sink)
+}
+
+// plan selects the objects of every batch and works out the name and action of each
+func (d *Downloader) plan(ctx context.Context, batches []batch, sink Sink) (*Plan, error) {
+	existing := map[string]int64{}
+	if existingSink, ok := sink.(ExistingSink); ok {
+		var err error
+		if existing, err = existingSink.Existing(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	plan := &Plan{}
+	selectCtx, span := tracing.Start(ctx, "select")
+	for _, b := range batches {
+		objects, skipped, errs := d.selectObjects(selectCtx, b.source, b.objects)
+		plan.Errors = append(plan.Errors, errs...)
+
+		for _, s := range skipped {
+			plan.Files = append(plan.Files, PlannedFile{
+				Key:       s.obj.Key,
+				VersionID: s.obj.VersionID,
+				Size:      s.obj.Size,
+				Action:    ActionSkip,
+				Reason:    s.reason,
+			})
+			plan.SkippedFiles++
+		}
+
+		for _, obj := range objects {
+			mapped, err := d.mappedName(b.source, obj)
+			if err != nil {
+				plan.Errors = append(plan.Errors, err)
+				continue
+			}
+
+			name := sinkName(sink, mapped)
+			file := PlannedFile{
+				Key:       obj.Key,
+				VersionID: obj.VersionID,
+				Size:      obj.Size,
+				Action:    ActionDownload,
+				Name:      d.outputName(name, obj),
+			}
+			// Sanitising also drops the trailing slash of directory markers, which changes nothing
+			if name != mapped && name != strings.TrimSuffix(mapped, "/") {
+				file.Unsanitized = mapped
+				plan.SanitizedFiles++
+			}
+
+			if size, ok := existing[file.Name]; ok {
+				file.Action = ActionOverwrite
+				file.ExistingSize = size
+				plan.OverwriteFiles++
+				plan.OverwriteBytes += obj.Size
+			} else {
+				plan.DownloadFiles++
+				plan.DownloadBytes += obj.Size
+			}
+
+			plan.Files = append(plan.Files, file)
+		}
+	}
+	span.SetAttributes(
+		attribute.Int("selected", plan.DownloadFiles+plan.OverwriteFiles),
+		attribute.Int("skipped", plan.SkippedFiles),
+		attribute.Int("errors", len(plan.Errors)),
+	)
+	span.End()
+
+	plan.Conflicts = findConflicts(plan.Files)
+	return plan, nil
+}
+
+// outputName is the name a download writes for an object whose name in the sink is name.
+// Directory markers and extracted archives name a directory, with a trailing "/".
+// Compression is only recognised by extension here; objects compressed according to their
+// Content-Encoding or Content-Type keep their names in the plan.
+func (d *Downloader) outputName(name string, obj providers.Object) string {
+	if strings.HasSuffix(obj.Key, "/") {
+		return strings.TrimSuffix(name, "/") + "/"
+	}
+	if d.extract {
+		if format, _, base := detectArchive(name); format != archiveNone {
+			return base + "/"
+		}
+	}
+	if d.decompress {
+		if c, decompressed := codecFromName(name); c != codecNone {
+			return decompressed
+		}
+	}
+	return name
+}
+
+// conflicts finds the names in sink that several of the queued objects would be written to.
+// Objects whose names cannot be mapped are left to fail when they are downloaded.
+func (d *Downloader) conflicts(queue []job, sink Sink) []Conflict {
+	files := make([]PlannedFile, 0, len(queue))
+	for _, j := range queue {
+		mapped, err := d.mappedName(j.source, j.obj)
+		if err != nil {
+			continue
+		}
+		files = append(files, PlannedFile{
+			Key:    j.obj.Key,
+			Action: ActionDownload,
+			Name:   d.outputName(sinkName(sink, mapped), j.obj),
+		})
+	}
+	return findConflicts(files)
+}
+
+// findConflicts finds the names that several planned files would be written to, and the
+// files whose names are also needed as a directory
+func findConflicts(files []PlannedFile) []Conflict {
+	keysByFile := make(map[string][]string)
+	dirs := make(map[string]string)
+	addDirs := func(name, key string) {
+		for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if _, ok := dirs[dir]; !ok {
+				dirs[dir] = key
+			}
+		}
+	}
+
+	for _, file := range files {
+		switch {
+		case file.Action == ActionSkip:
+		case strings.HasSuffix(file.Name, "/"):
+			dir := strings.TrimSuffix(file.Name, "/")
+			if _, ok := dirs[dir]; !ok {
+				dirs[dir] = file.Key
+			}
+			addDirs(dir, file.Key)
+		default:
+			keysByFile[file.Name] = append(keysByFile[file.Name], file.Key)
+			addDirs(file.Name, file.Key)
+		}
+	}
+
+	var conflicts []Conflict
+	for name, keys := range keysByFile {
+		if dirKey, ok := dirs[name]; ok {
+			keys = append(keys, dirKey)
+		}
+		if len(keys) > 1 {
+			conflicts = append(conflicts, Conflict{Name: name, Keys: keys})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Name < conflicts[j].Name })
+
+	return conflicts
+}
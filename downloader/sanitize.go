@@ -0,0 +1,78 @@
+package downloader
+
+import (
+	"runtime"
+	"strings"
+
+	"download-file-from-bucket/providers"
+)
+
+// mappedName returns the name an object is written under in its source's part of the sink,
+// as given by its key and the path mapping, before any archive or compression extension is removed
+func (d *Downloader) mappedName(source *Source, obj providers.Object) (string, error) {
+	name := relativeName(obj.Key, source.Prefix)
+
+	// Directory markers keep their names; mapped layouts skip them when selecting
+	if !strings.HasSuffix(obj.Key, "/") {
+		var err error
+		if name, err = d.paths.Map(name, obj); err != nil {
+			return "", err
+		}
+	}
+
+	return source.join(name), nil
+}
+
+// LocalName is the file name, relative to a directory destination, that an object downloaded
+// from prefix is written under when no path mapping is in use
+func LocalName(key, prefix string) string {
+	return sanitizeName(relativeName(key, prefix))
+}
+
+// sinkName makes a name safe for the sink. Names written to an ObjectSink become object
+// keys, which may hold any character, so only names for other sinks are sanitised.
+func sinkName(sink Sink, name string) string {
+	if _, ok := sink.(ObjectSink); ok {
+		return name
+	}
+	return sanitizeName(name)
+}
+
+// sanitizeName turns an object key into a relative file name that stays inside the destination.
+// Empty and "." elements are dropped, ".." elements become "__", and control characters are
+// replaced with "_", as are the characters and trailing dots and spaces that Windows rejects there.
+func sanitizeName(name string) string {
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			part = "__"
+		}
+
+		part = strings.Map(replaceUnsafe, part)
+		if runtime.GOOS == "windows" {
+			if trimmed := strings.TrimRight(part, ". "); trimmed != part {
+				part = trimmed + strings.Repeat("_", len(part)-len(trimmed))
+			}
+		}
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 0 {
+		return "_"
+	}
+	return strings.Join(parts, "/")
+}
+
+// replaceUnsafe maps a character that cannot appear in a file name to "_"
+func replaceUnsafe(r rune) rune {
+	if r < 0x20 || r == 0x7f {
+		return '_'
+	}
+	if runtime.GOOS == "windows" && strings.ContainsRune(`<>:"\|?*`, r) {
+		return '_'
+	}
+	return r
+}
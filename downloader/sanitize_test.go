@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("names are also rewritten for Windows")
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"a/b.txt", "a/b.txt"},
+		{"/a/b.txt", "a/b.txt"},
+		{"a//b.txt", "a/b.txt"},
+		{"a/./b.txt", "a/b.txt"},
+		{"../x", "__/x"},
+		{"a/../../x", "a/__/__/x"},
+		{"..", "__"},
+		{"a/b\x00c\nd", "a/b_c_d"},
+		{"tab\there", "tab_here"},
+		{"del\x7f", "del_"},
+		{"", "_"},
+		{"/", "_"},
+		{"./.", "_"},
+		{"...", "..."},
+		{"a:b*c?", "a:b*c?"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeName(tt.name); got != tt.want {
+			t.Errorf("sanitizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSinkNameKeepsObjectKeys(t *testing.T) {
+	sink := NewProviderSink(context.Background(), newFakeProvider(nil), "dest")
+	if got := sinkName(sink, "a//../b"); got != "a//../b" {
+		t.Errorf("sinkName for a bucket = %q, want the key unchanged", got)
+	}
+	if got := sinkName(NewDirSink(t.TempDir()), "a//../b"); got != "a/__/b" {
+		t.Errorf("sinkName for a directory = %q, want a/__/b", got)
+	}
+}
+
+func TestDownloadKeepsTraversingKeysInside(t *testing.T) {
+	provider := newFakeProvider(map[string]string{
+		"data/../../escaped": "outside",
+		"data/ok.txt":        "inside",
+	})
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "dest")
+	result, err := NewDownloader(provider, Options{}).DownloadFolder(context.Background(), "data/", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FailedFiles != 0 {
+		t.Fatalf("download failed: %v", result.Errors)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("file was written outside the destination")
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "__", "__", "escaped"))
+	if err != nil || string(content) != "outside" {
+		t.Errorf("sanitized file = %q, %v", content, err)
+	}
+}
@@ -0,0 +1,344 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"download-file-from-bucket/localfiles"
+	"download-file-from-bucket/providers"
+)
+
+// Sink receives the files produced by a download.
+// Names are slash-separated and relative to the root of the sink.
+// Implementations must be safe for concurrent use.
+type Sink interface {
+	// WriteFile stores the content of r under name and returns the number of bytes written
+	WriteFile(name string, r io.Reader, modTime time.Time) (int64, error)
+
+	// Mkdir records a directory, which may be empty
+	Mkdir(name string) error
+
+	// Close flushes anything the sink has buffered
+	Close() error
+}
+
+// ObjectSink is a Sink that also keeps object properties such as content type and
+// user metadata. The downloader fetches each object's metadata before writing to it.
+type ObjectSink interface {
+	Sink
+
+	// WriteObject stores the content of r under name along with the properties of obj
+	WriteObject(name string, r io.Reader, obj providers.Object) (int64, error)
+}
+
+// ExistingSink is a Sink that can list the files it already holds, so a plan can
+// tell new files from ones that would be overwritten
+type ExistingSink interface {
+	Sink
+
+	// Existing returns the size of every file already in the sink, by name
+	Existing(ctx context.Context) (map[string]int64, error)
+}
+
+// DirSink writes files into a local directory tree
+type DirSink struct {
+	root string
+}
+
+// NewDirSink creates a sink that writes files under root
+func NewDirSink(root string) *DirSink {
+	return &DirSink{root: root}
+}
+
+// WriteFile creates the file, and any missing parent directories, with the content of r
+func (s *DirSink) WriteFile(name string, r io.Reader, modTime time.Time) (int64, error) {
+	localPath := s.LocalPath(name)
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory for %s: %w", localPath, err)
+	}
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create local file %s: %w", localPath, err)
+	}
+	defer file.Close()
+
+	n, err := io.Copy(file, r)
+	if err != nil {
+		return n, fmt.Errorf("failed to write data to %s: %w", localPath, err)
+	}
+
+	if !modTime.IsZero() {
+		os.Chtimes(localPath, modTime, modTime)
+	}
+
+	return n, nil
+}
+
+// LocalPath returns where the file with the given name is written
+func (s *DirSink) LocalPath(name string) string {
+	return filepath.Join(s.root, filepath.FromSlash(name))
+}
+
+// Existing walks the directory tree; a root that does not exist yet holds no files
+func (s *DirSink) Existing(ctx context.Context) (map[string]int64, error) {
+	files, err := localfiles.List(s.root)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64, len(files))
+	for _, file := range files {
+		sizes[file.Name] = file.Size
+	}
+	return sizes, nil
+}
+
+// Mkdir creates the directory
+func (s *DirSink) Mkdir(name string) error {
+	return os.MkdirAll(filepath.Join(s.root, filepath.FromSlash(name)), 0755)
+}
+
+// Close does nothing; files are complete once WriteFile returns
+func (s *DirSink) Close() error {
+	return nil
+}
+
+// TarSink writes files into a tar stream, optionally gzip-compressed
+type TarSink struct {
+	mu sync.Mutex
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+// NewTarSink creates a sink that writes a tar stream to w
+func NewTarSink(w io.Writer, compress bool) *TarSink {
+	s := &TarSink{}
+	if compress {
+		s.gz = gzip.NewWriter(w)
+		w = s.gz
+	}
+	s.tw = tar.NewWriter(w)
+	return s
+}
+
+// WriteFile adds a file to the archive. Its content is spooled to a temporary file first,
+// because tar headers need the size up front and downloads run concurrently.
+func (s *TarSink) WriteFile(name string, r io.Reader, modTime time.Time) (int64, error) {
+	tmp, size, err := spool(r)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  modTime,
+	}
+	if err := s.tw.WriteHeader(hdr); err != nil {
+		return 0, fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+
+	n, err := io.Copy(s.tw, tmp)
+	if err != nil {
+		return n, fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+
+	return n, nil
+}
+
+// Mkdir adds a directory entry to the archive
+func (s *TarSink) Mkdir(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     strings.TrimSuffix(name, "/") + "/",
+		Mode:     0755,
+		ModTime:  time.Now(),
+	}
+	return s.tw.WriteHeader(hdr)
+}
+
+// Close finishes the tar stream
+func (s *TarSink) Close() error {
+	if err := s.tw.Close(); err != nil {
+		return err
+	}
+	if s.gz != nil {
+		return s.gz.Close()
+	}
+	return nil
+}
+
+// ZipSink writes files into a zip archive
+type ZipSink struct {
+	mu sync.Mutex
+	zw *zip.Writer
+}
+
+// NewZipSink creates a sink that writes a zip archive to w
+func NewZipSink(w io.Writer) *ZipSink {
+	return &ZipSink{zw: zip.NewWriter(w)}
+}
+
+// WriteFile adds a file to the archive. Its content is spooled to a temporary file first,
+// so concurrent downloads don't hold the archive while they wait on the network.
+func (s *ZipSink) WriteFile(name string, r io.Reader, modTime time.Time) (int64, error) {
+	tmp, _, err := spool(r)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, err := s.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+
+	n, err := io.Copy(w, tmp)
+	if err != nil {
+		return n, fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+
+	return n, nil
+}
+
+// Mkdir adds a directory entry to the archive
+func (s *ZipSink) Mkdir(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.zw.Create(strings.TrimSuffix(name, "/") + "/")
+	return err
+}
+
+// Close writes the zip central directory
+func (s *ZipSink) Close() error {
+	return s.zw.Close()
+}
+
+// ProviderSink uploads files into another bucket, streaming them without touching local disk
+type ProviderSink struct {
+	ctx      context.Context
+	provider providers.Provider
+	prefix   string
+}
+
+// NewProviderSink creates a sink that uploads files under prefix using provider.
+// Uploads run under ctx, so cancelling it aborts them.
+func NewProviderSink(ctx context.Context, provider providers.Provider, prefix string) *ProviderSink {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &ProviderSink{ctx: ctx, provider: provider, prefix: prefix}
+}
+
+// WriteFile uploads the content of r to the key for name
+func (s *ProviderSink) WriteFile(name string, r io.Reader, modTime time.Time) (int64, error) {
+	return s.upload(name, r, providers.UploadOptions{})
+}
+
+// WriteObject uploads the content of r to the key for name, keeping the content type and metadata of obj
+func (s *ProviderSink) WriteObject(name string, r io.Reader, obj providers.Object) (int64, error) {
+	return s.upload(name, r, providers.UploadOptions{
+		ContentType:     obj.ContentType,
+		ContentEncoding: obj.ContentEncoding,
+		Metadata:        obj.Metadata,
+	})
+}
+
+// Mkdir uploads an empty directory marker
+func (s *ProviderSink) Mkdir(name string) error {
+	_, err := s.upload(strings.TrimSuffix(name, "/")+"/", strings.NewReader(""), providers.UploadOptions{})
+	return err
+}
+
+// Close does nothing; uploads are complete once WriteFile returns
+func (s *ProviderSink) Close() error {
+	return nil
+}
+
+// Existing lists the objects under the prefix; directory markers are left out
+func (s *ProviderSink) Existing(ctx context.Context) (map[string]int64, error) {
+	objects, err := s.provider.ListObjects(ctx, s.prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list destination objects: %w", err)
+	}
+
+	files := make(map[string]int64, len(objects))
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, "/") {
+			files[strings.TrimPrefix(obj.Key, s.prefix)] = obj.Size
+		}
+	}
+
+	return files, nil
+}
+
+func (s *ProviderSink) upload(name string, r io.Reader, opts providers.UploadOptions) (int64, error) {
+	cr := &countingReader{r: r}
+	if err := s.provider.UploadObject(s.ctx, s.prefix+name, cr, opts); err != nil {
+		return cr.n, err
+	}
+	return cr.n, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// spool copies r into a temporary file and returns it rewound, along with its size.
+// The caller must close and remove the file.
+func spool(r io.Reader) (*os.File, int64, error) {
+	tmp, err := os.CreateTemp("", "download-bucket-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, 0, fmt.Errorf("failed to spool data: %w", err)
+	}
+
+	return tmp, size, nil
+}
@@ -0,0 +1,226 @@
+package downloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"download-file-from-bucket/providers"
+)
+
+// sinkTestObjects are downloaded into each archive sink and read back
+var sinkTestObjects = map[string]string{
+	"data/a.txt":       "alpha",
+	"data/dir/b.txt":   "bravo",
+	"data/dir/c/d.bin": strings.Repeat("x", 100000),
+	"data/empty":       "",
+}
+
+// sinkTestFiles is what the archives hold: names relative to the prefix, and the empty directory
+var sinkTestFiles = map[string]string{
+	"a.txt":       "alpha",
+	"dir/b.txt":   "bravo",
+	"dir/c/d.bin": strings.Repeat("x", 100000),
+	"empty":       "",
+	"logs/":       "",
+}
+
+func fillSink(t *testing.T, sink Sink) {
+	t.Helper()
+
+	d := NewDownloader(newFakeProvider(sinkTestObjects), Options{Concurrency: 4})
+	result, err := d.DownloadFolderTo(context.Background(), "data/", sink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FailedFiles != 0 || result.SuccessfulFiles != len(sinkTestObjects) {
+		t.Fatalf("downloaded %d files, %d failed: %v", result.SuccessfulFiles, result.FailedFiles, result.Errors)
+	}
+	if err := sink.Mkdir("logs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeDir != strings.HasSuffix(hdr.Name, "/") {
+			t.Errorf("%s: type %c does not match the name", hdr.Name, hdr.Typeflag)
+		}
+		files[hdr.Name] = string(content)
+	}
+}
+
+func TestTarSink(t *testing.T) {
+	var buf bytes.Buffer
+	fillSink(t, NewTarSink(&buf, false))
+
+	if got := readTar(t, &buf); !reflect.DeepEqual(got, sinkTestFiles) {
+		t.Errorf("archive holds %v", keysOf(got))
+	}
+}
+
+func TestTarSinkCompressed(t *testing.T) {
+	var buf bytes.Buffer
+	fillSink(t, NewTarSink(&buf, true))
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readTar(t, gz); !reflect.DeepEqual(got, sinkTestFiles) {
+		t.Errorf("archive holds %v", keysOf(got))
+	}
+}
+
+func TestZipSink(t *testing.T) {
+	var buf bytes.Buffer
+	fillSink(t, NewZipSink(&buf))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[f.Name] = string(content)
+	}
+	if !reflect.DeepEqual(got, sinkTestFiles) {
+		t.Errorf("archive holds %v", keysOf(got))
+	}
+}
+
+func TestArchiveSinksKeepModTime(t *testing.T) {
+	modTime := time.Date(2024, 5, 6, 7, 8, 10, 0, time.UTC)
+
+	var tarBuf bytes.Buffer
+	tarSink := NewTarSink(&tarBuf, false)
+	if _, err := tarSink.WriteFile("a.txt", strings.NewReader("a"), modTime); err != nil {
+		t.Fatal(err)
+	}
+	tarSink.Close()
+	hdr, err := tar.NewReader(&tarBuf).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hdr.ModTime.Equal(modTime) {
+		t.Errorf("tar mod time %v, want %v", hdr.ModTime, modTime)
+	}
+
+	var zipBuf bytes.Buffer
+	zipSink := NewZipSink(&zipBuf)
+	if _, err := zipSink.WriteFile("a.txt", strings.NewReader("a"), modTime); err != nil {
+		t.Fatal(err)
+	}
+	zipSink.Close()
+	zr, err := zip.NewReader(bytes.NewReader(zipBuf.Bytes()), int64(zipBuf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := zr.File[0].Modified; !got.Equal(modTime) {
+		t.Errorf("zip mod time %v, want %v", got, modTime)
+	}
+}
+
+func keysOf(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestProviderSinkCopiesProperties(t *testing.T) {
+	ctx := context.Background()
+
+	source := newFakeProvider(map[string]string{"other/c.txt": "outside the prefix"})
+	seed := map[string]providers.UploadOptions{
+		"data/a.json":       {ContentType: "application/json", Metadata: map[string]string{"owner": "ops"}},
+		"data/sub/b.txt.gz": {ContentType: "text/plain", ContentEncoding: "gzip"},
+		"data/plain":        {},
+	}
+	for key, opts := range seed {
+		if err := source.UploadObject(ctx, key, strings.NewReader("content of "+key), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dest := newFakeProvider(nil)
+	sink := NewProviderSink(ctx, dest, "backup")
+
+	d := NewDownloader(source, Options{Concurrency: 2})
+	result, err := d.DownloadFolderTo(ctx, "data/", sink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessfulFiles != len(seed) || result.FailedFiles != 0 {
+		t.Fatalf("copied %d files, %d failed: %v", result.SuccessfulFiles, result.FailedFiles, result.Errors)
+	}
+
+	if len(dest.objects) != len(seed) {
+		t.Errorf("destination holds %d objects, want %d", len(dest.objects), len(seed))
+	}
+	for key, opts := range seed {
+		destKey := "backup/" + strings.TrimPrefix(key, "data/")
+		if got := string(dest.objects[destKey]); got != "content of "+key {
+			t.Errorf("%s: content %q", destKey, got)
+		}
+		if got := dest.uploads[destKey]; !reflect.DeepEqual(got, opts) {
+			t.Errorf("%s: uploaded with %+v, want %+v", destKey, got, opts)
+		}
+	}
+
+	if err := sink.Mkdir("logs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dest.objects["backup/logs/"]; !ok {
+		t.Error("Mkdir did not upload a directory marker")
+	}
+
+	existing, err := sink.Existing(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{
+		"a.json":       int64(len("content of data/a.json")),
+		"sub/b.txt.gz": int64(len("content of data/sub/b.txt.gz")),
+		"plain":        int64(len("content of data/plain")),
+	}
+	if !reflect.DeepEqual(existing, want) {
+		t.Errorf("Existing = %v, want %v", existing, want)
+	}
+}
@@ -0,0 +1,215 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Metadata keys written by the S3 encryption client
+const (
+	metaKeyV1   = "x-amz-key"
+	metaKeyV2   = "x-amz-key-v2"
+	metaIV      = "x-amz-iv"
+	metaCEKAlg  = "x-amz-cek-alg"
+	metaWrapAlg = "x-amz-wrap-alg"
+	metaMatDesc = "x-amz-matdesc"
+	metaTagLen  = "x-amz-tag-len"
+)
+
+// Content encryption algorithms
+const (
+	AlgorithmAESGCM = "AES/GCM/NoPadding"
+	AlgorithmAESCBC = "AES/CBC/PKCS5Padding"
+)
+
+// Envelope holds the encryption details stored alongside a client-side encrypted object
+type Envelope struct {
+	// WrappedKey is the encrypted content encryption key
+	WrappedKey []byte
+	// WrapAlgorithm is how WrappedKey was encrypted; empty for the V1 format
+	WrapAlgorithm string
+	// ContentAlgorithm is how the object data was encrypted
+	ContentAlgorithm string
+	IV               []byte
+	// TagLength is the GCM authentication tag length in bits
+	TagLength int
+	// MaterialDescription identifies the master key the data key was wrapped with
+	MaterialDescription map[string]string
+}
+
+// ParseEnvelope reads an envelope from object metadata.
+// It returns nil if the object was not encrypted client-side.
+func ParseEnvelope(metadata map[string]string) (*Envelope, error) {
+	meta := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		meta[strings.ToLower(k)] = v
+	}
+
+	env := &Envelope{}
+	wrapped, ok := meta[metaKeyV2]
+	if ok {
+		env.WrapAlgorithm = meta[metaWrapAlg]
+		env.ContentAlgorithm = meta[metaCEKAlg]
+	} else if wrapped, ok = meta[metaKeyV1]; ok {
+		// The V1 format always used CBC for content
+		env.ContentAlgorithm = AlgorithmAESCBC
+	} else {
+		return nil, nil
+	}
+
+	var err error
+	if env.WrappedKey, err = base64.StdEncoding.DecodeString(wrapped); err != nil {
+		return nil, fmt.Errorf("invalid wrapped key in envelope: %w", err)
+	}
+	if env.IV, err = base64.StdEncoding.DecodeString(meta[metaIV]); err != nil || len(env.IV) == 0 {
+		return nil, fmt.Errorf("invalid or missing IV in envelope")
+	}
+
+	env.TagLength = 128
+	if tagLen := meta[metaTagLen]; tagLen != "" {
+		if _, err := fmt.Sscanf(tagLen, "%d", &env.TagLength); err != nil {
+			return nil, fmt.Errorf("invalid tag length %q in envelope", tagLen)
+		}
+	}
+
+	if matDesc := meta[metaMatDesc]; matDesc != "" {
+		if err := json.Unmarshal([]byte(matDesc), &env.MaterialDescription); err != nil {
+			return nil, fmt.Errorf("invalid material description in envelope: %w", err)
+		}
+	}
+
+	return env, nil
+}
+
+// Decrypter decrypts objects written by the S3 encryption client
+type Decrypter struct {
+	keys KeyProvider
+}
+
+// NewDecrypter creates a decrypter that unwraps data keys with the given key provider
+func NewDecrypter(keys KeyProvider) *Decrypter {
+	return &Decrypter{keys: keys}
+}
+
+// Decrypt returns a reader of the plaintext of r, using the envelope found in the object metadata.
+// If the metadata holds no envelope r is returned unchanged. The reader must be closed to
+// release any temporary file used while decrypting.
+func (d *Decrypter) Decrypt(ctx context.Context, metadata map[string]string, r io.Reader) (io.ReadCloser, error) {
+	env, err := ParseEnvelope(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if env == nil {
+		return io.NopCloser(r), nil
+	}
+
+	key, err := d.keys.UnwrapKey(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+
+	switch env.ContentAlgorithm {
+	case AlgorithmAESGCM:
+		return decryptGCM(block, env, r)
+	case AlgorithmAESCBC:
+		if len(env.IV) != aes.BlockSize {
+			return nil, fmt.Errorf("invalid IV length %d for %s", len(env.IV), AlgorithmAESCBC)
+		}
+		return io.NopCloser(&cbcReader{src: r, mode: cipher.NewCBCDecrypter(block, env.IV)}), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encryption algorithm %q", env.ContentAlgorithm)
+	}
+}
+
+// cbcReader streams CBC decryption, holding back the final block until the end
+// of the ciphertext so its PKCS#5 padding can be removed
+type cbcReader struct {
+	src     io.Reader
+	mode    cipher.BlockMode
+	pending []byte // decrypted bytes not yet returned
+	last    []byte // the most recent decrypted block, which may hold padding
+	buf     []byte
+	eof     bool
+}
+
+func (c *cbcReader) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if c.eof {
+			return 0, io.EOF
+		}
+		if err := c.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// fill decrypts the next chunk of ciphertext into pending
+func (c *cbcReader) fill() error {
+	if c.buf == nil {
+		// A multiple of the block size, so only the final read can end mid-block
+		c.buf = make([]byte, 32*1024)
+	}
+
+	n, err := io.ReadFull(c.src, c.buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		if n%aes.BlockSize != 0 {
+			return errors.New("failed to decrypt object: ciphertext is not a multiple of the block size")
+		}
+		c.eof = true
+	} else if err != nil {
+		return err
+	}
+
+	chunk := c.buf[:n]
+	c.mode.CryptBlocks(chunk, chunk)
+
+	out := append(append([]byte(nil), c.last...), chunk...)
+	if c.eof {
+		unpadded, err := unpad(out)
+		if err != nil {
+			return err
+		}
+		c.pending = unpadded
+		c.last = nil
+		return nil
+	}
+
+	// Keep the last block back in case it is the final, padded one
+	c.pending = out[:len(out)-aes.BlockSize]
+	c.last = out[len(out)-aes.BlockSize:]
+
+	return nil
+}
+
+// unpad removes PKCS#5 padding
+func unpad(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("failed to decrypt object: empty ciphertext")
+	}
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(data) {
+		return nil, errors.New("failed to decrypt object: invalid padding")
+	}
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("failed to decrypt object: invalid padding")
+		}
+	}
+	return data[:len(data)-padding], nil
+}
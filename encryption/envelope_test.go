@@ -0,0 +1,244 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// staticKey is a key provider that returns the same data key for every envelope
+type staticKey []byte
+
+func (k staticKey) UnwrapKey(ctx context.Context, env *Envelope) ([]byte, error) {
+	return k, nil
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func envelopeMetadata(algorithm string, iv []byte) map[string]string {
+	return map[string]string{
+		"X-Amz-Key-V2":   base64.StdEncoding.EncodeToString([]byte("wrapped")),
+		"X-Amz-Wrap-Alg": "test",
+		"X-Amz-Cek-Alg":  algorithm,
+		"X-Amz-Iv":       base64.StdEncoding.EncodeToString(iv),
+	}
+}
+
+func decryptAll(t *testing.T, key []byte, metadata map[string]string, ciphertext []byte) ([]byte, error) {
+	t.Helper()
+	r, err := NewDecrypter(staticKey(key)).Decrypt(context.Background(), metadata, bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func TestDecryptGCM(t *testing.T) {
+	key := randomBytes(t, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, nonceSize := range []int{12, 16} {
+		gcm, err := cipher.NewGCMWithNonceSize(block, nonceSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, size := range []int{0, 1, 15, 16, 17, 100, 1 << 20} {
+			nonce := randomBytes(t, nonceSize)
+			plaintext := randomBytes(t, size)
+			ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+
+			got, err := decryptAll(t, key, envelopeMetadata(AlgorithmAESGCM, nonce), ciphertext)
+			if err != nil {
+				t.Fatalf("nonce %d, size %d: %v", nonceSize, size, err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("nonce %d, size %d: plaintext differs", nonceSize, size)
+			}
+		}
+	}
+}
+
+func TestDecryptGCMRejectsTampering(t *testing.T) {
+	key := randomBytes(t, 16)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := randomBytes(t, gcm.NonceSize())
+	ciphertext := gcm.Seal(nil, nonce, randomBytes(t, 1000), nil)
+
+	for _, i := range []int{0, 500, len(ciphertext) - 1} {
+		tampered := bytes.Clone(ciphertext)
+		tampered[i] ^= 1
+		if _, err := decryptAll(t, key, envelopeMetadata(AlgorithmAESGCM, nonce), tampered); err == nil {
+			t.Errorf("byte %d flipped: expected an authentication error", i)
+		}
+	}
+
+	if _, err := decryptAll(t, key, envelopeMetadata(AlgorithmAESGCM, nonce), ciphertext[:10]); err == nil {
+		t.Error("truncated ciphertext: expected an error")
+	}
+}
+
+func TestDecryptGCMRemovesSpooledFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+
+	key := randomBytes(t, 16)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := randomBytes(t, gcm.NonceSize())
+	ciphertext := gcm.Seal(nil, nonce, []byte("hello"), nil)
+
+	if _, err := decryptAll(t, key, envelopeMetadata(AlgorithmAESGCM, nonce), ciphertext); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext[0] ^= 1
+	if _, err := decryptAll(t, key, envelopeMetadata(AlgorithmAESGCM, nonce), ciphertext); err == nil {
+		t.Fatal("expected an authentication error")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 0 {
+		t.Errorf("spooled files left behind: %v", files)
+	}
+}
+
+func TestDecryptCBC(t *testing.T) {
+	key := randomBytes(t, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 1, 15, 16, 17, 100000} {
+		iv := randomBytes(t, aes.BlockSize)
+		plaintext := randomBytes(t, size)
+
+		padding := aes.BlockSize - size%aes.BlockSize
+		padded := append(bytes.Clone(plaintext), bytes.Repeat([]byte{byte(padding)}, padding)...)
+		ciphertext := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+		got, err := decryptAll(t, key, envelopeMetadata(AlgorithmAESCBC, iv), ciphertext)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("size %d: plaintext differs", size)
+		}
+	}
+}
+
+func TestDecryptWithoutEnvelope(t *testing.T) {
+	got, err := decryptAll(t, nil, map[string]string{"Other": "x"}, []byte("plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "plain" {
+		t.Errorf("got %q, want the object unchanged", got)
+	}
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestUnwrapAESWrap(t *testing.T) {
+	// RFC 3394 section 4.1: wrap 128 bits of key data with a 128-bit KEK
+	kek := mustHex(t, "000102030405060708090A0B0C0D0E0F")
+	wrapped := mustHex(t, "1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+	want := mustHex(t, "00112233445566778899AABBCCDDEEFF")
+
+	keys, err := NewMasterKeyProvider(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := keys.UnwrapKey(context.Background(), &Envelope{WrapAlgorithm: WrapAESWrap, WrappedKey: wrapped})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %X, want %X", got, want)
+	}
+
+	wrapped[0] ^= 1
+	if _, err := keys.UnwrapKey(context.Background(), &Envelope{WrapAlgorithm: WrapAESWrap, WrappedKey: wrapped}); err == nil {
+		t.Error("expected an error for a corrupted wrapped key")
+	}
+}
+
+func TestUnwrapAESGCM(t *testing.T) {
+	master := randomBytes(t, 32)
+	dataKey := randomBytes(t, 32)
+
+	block, _ := aes.NewCipher(master)
+	gcm, _ := cipher.NewGCM(block)
+	nonce := randomBytes(t, gcm.NonceSize())
+	wrapped := append(nonce, gcm.Seal(nil, nonce, dataKey, []byte(AlgorithmAESGCM))...)
+
+	keys, err := NewMasterKeyProvider(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &Envelope{WrapAlgorithm: WrapAESGCM, ContentAlgorithm: AlgorithmAESGCM, WrappedKey: wrapped}
+	got, err := keys.UnwrapKey(context.Background(), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, dataKey) {
+		t.Error("unwrapped key differs")
+	}
+
+	// The content algorithm is authenticated with the key
+	env.ContentAlgorithm = AlgorithmAESCBC
+	if _, err := keys.UnwrapKey(context.Background(), env); err == nil {
+		t.Error("expected an error when the content algorithm does not match")
+	}
+}
+
+func TestUnwrapV1(t *testing.T) {
+	master := randomBytes(t, 16)
+	dataKey := randomBytes(t, 32)
+
+	block, _ := aes.NewCipher(master)
+	padded := append(bytes.Clone(dataKey), bytes.Repeat([]byte{aes.BlockSize}, aes.BlockSize)...)
+	wrapped := make([]byte, len(padded))
+	for i := 0; i < len(padded); i += aes.BlockSize {
+		block.Encrypt(wrapped[i:i+aes.BlockSize], padded[i:i+aes.BlockSize])
+	}
+
+	keys, err := NewMasterKeyProvider(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := keys.UnwrapKey(context.Background(), &Envelope{WrappedKey: wrapped})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, dataKey) {
+		t.Error("unwrapped key differs")
+	}
+}
@@ -0,0 +1,275 @@
+package encryption
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// gcmTagSize is the size of the authentication tag the S3 encryption client appends to GCM payloads
+const gcmTagSize = 16
+
+// gcmStandardNonceSize is the nonce size for which the initial counter is the nonce itself
+const gcmStandardNonceSize = 12
+
+// decryptGCM decrypts a GCM payload without holding it in memory. The tag can only be
+// checked once all of the ciphertext has been read, so the ciphertext is spooled to a
+// temporary file while it is authenticated, and the plaintext is then streamed from that
+// file. Closing the returned reader removes the file.
+func decryptGCM(block cipher.Block, env *Envelope, r io.Reader) (io.ReadCloser, error) {
+	if env.TagLength != gcmTagSize*8 {
+		return nil, fmt.Errorf("unsupported GCM tag length %d", env.TagLength)
+	}
+	if len(env.IV) == 0 {
+		return nil, errors.New("invalid GCM nonce")
+	}
+
+	g := newGHash(block)
+	counter := g.initialCounter(env.IV)
+
+	tmp, err := os.CreateTemp("", "download-bucket-gcm-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	fail := func(err error) (io.ReadCloser, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	// The last gcmTagSize bytes are the tag, so everything before them is hashed as ciphertext
+	auth := &tagSplitter{hash: g}
+	size, err := io.Copy(tmp, io.TeeReader(r, auth))
+	if err != nil {
+		return fail(fmt.Errorf("failed to spool encrypted object: %w", err))
+	}
+	if size < gcmTagSize {
+		return fail(errors.New("failed to decrypt object: ciphertext shorter than the GCM tag"))
+	}
+
+	var mask [gcmTagSize]byte
+	block.Encrypt(mask[:], counter[:])
+	expected := g.sum(uint64(size-gcmTagSize), mask)
+	if subtle.ConstantTimeCompare(expected[:], auth.held) != 1 {
+		return fail(errors.New("failed to decrypt object: authentication failed"))
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fail(fmt.Errorf("failed to read spooled object: %w", err))
+	}
+
+	incrementCounter(&counter)
+	return &gcmReader{
+		file:   tmp,
+		src:    bufio.NewReader(io.LimitReader(tmp, size-gcmTagSize)),
+		stream: &counterStream{block: block, counter: counter},
+	}, nil
+}
+
+// gcmReader streams the plaintext of an authenticated GCM payload from its spooled ciphertext
+type gcmReader struct {
+	file   *os.File
+	src    io.Reader
+	stream *counterStream
+}
+
+func (g *gcmReader) Read(p []byte) (int, error) {
+	n, err := g.src.Read(p)
+	g.stream.XORKeyStream(p[:n], p[:n])
+	return n, err
+}
+
+// Close removes the spooled ciphertext
+func (g *gcmReader) Close() error {
+	err := g.file.Close()
+	os.Remove(g.file.Name())
+	return err
+}
+
+// counterStream is the GCM keystream: AES in counter mode where only the last 32 bits
+// of the counter block are incremented
+type counterStream struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	key     [aes.BlockSize]byte
+	used    int
+}
+
+func (s *counterStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == 0 || s.used == aes.BlockSize {
+			s.block.Encrypt(s.key[:], s.counter[:])
+			incrementCounter(&s.counter)
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.key[s.used]
+		s.used++
+	}
+}
+
+// incrementCounter adds one to the last 32 bits of a GCM counter block
+func incrementCounter(counter *[aes.BlockSize]byte) {
+	n := binary.BigEndian.Uint32(counter[12:])
+	binary.BigEndian.PutUint32(counter[12:], n+1)
+}
+
+// tagSplitter hashes everything written to it except the last gcmTagSize bytes, which it holds back as the tag
+type tagSplitter struct {
+	hash *ghash
+	held []byte
+}
+
+func (t *tagSplitter) Write(p []byte) (int, error) {
+	t.held = append(t.held, p...)
+	if over := len(t.held) - gcmTagSize; over > 0 {
+		t.hash.write(t.held[:over])
+		t.held = append(t.held[:0], t.held[over:]...)
+	}
+	return len(p), nil
+}
+
+// ghash computes the GCM authentication hash incrementally. The multiplication in GF(2^128)
+// uses 4-bit tables, as in the standard library's generic implementation.
+type ghash struct {
+	table   [16]fieldElement
+	y       fieldElement
+	partial [aes.BlockSize]byte
+	n       int
+}
+
+// fieldElement is an element of GF(2^128); low holds the first 8 bytes of a block, high the last 8
+type fieldElement struct {
+	low, high uint64
+}
+
+// newGHash prepares the multiplication table for the hash key E(K, 0^128)
+func newGHash(block cipher.Block) *ghash {
+	var key [aes.BlockSize]byte
+	block.Encrypt(key[:], key[:])
+	x := fieldElement{binary.BigEndian.Uint64(key[:8]), binary.BigEndian.Uint64(key[8:])}
+
+	g := &ghash{}
+	g.table[reverseBits(1)] = x
+	for i := 2; i < 16; i += 2 {
+		g.table[reverseBits(i)] = double(g.table[reverseBits(i/2)])
+		g.table[reverseBits(i+1)] = fieldElement{g.table[reverseBits(i)].low ^ x.low, g.table[reverseBits(i)].high ^ x.high}
+	}
+	return g
+}
+
+// initialCounter derives the pre-counter block J0 from the nonce
+func (g *ghash) initialCounter(nonce []byte) [aes.BlockSize]byte {
+	var counter [aes.BlockSize]byte
+	if len(nonce) == gcmStandardNonceSize {
+		copy(counter[:], nonce)
+		counter[aes.BlockSize-1] = 1
+		return counter
+	}
+
+	h := &ghash{table: g.table}
+	h.write(nonce)
+	h.flush()
+	h.y.high ^= uint64(len(nonce)) * 8
+	h.mul(&h.y)
+	binary.BigEndian.PutUint64(counter[:8], h.y.low)
+	binary.BigEndian.PutUint64(counter[8:], h.y.high)
+	return counter
+}
+
+// write hashes data, keeping back a partial block until more data or the end arrives
+func (g *ghash) write(data []byte) {
+	for len(data) > 0 {
+		c := copy(g.partial[g.n:], data)
+		g.n += c
+		data = data[c:]
+		if g.n == aes.BlockSize {
+			g.block(g.partial[:])
+			g.n = 0
+		}
+	}
+}
+
+// flush hashes a final partial block, padded with zeros
+func (g *ghash) flush() {
+	if g.n > 0 {
+		for i := g.n; i < aes.BlockSize; i++ {
+			g.partial[i] = 0
+		}
+		g.block(g.partial[:])
+		g.n = 0
+	}
+}
+
+// sum finishes the hash of ciphertextLen bytes of ciphertext with no additional data
+// and masks it into the tag
+func (g *ghash) sum(ciphertextLen uint64, mask [gcmTagSize]byte) [gcmTagSize]byte {
+	g.flush()
+	g.y.high ^= ciphertextLen * 8
+	g.mul(&g.y)
+
+	var tag [gcmTagSize]byte
+	binary.BigEndian.PutUint64(tag[:8], g.y.low)
+	binary.BigEndian.PutUint64(tag[8:], g.y.high)
+	subtle.XORBytes(tag[:], tag[:], mask[:])
+	return tag
+}
+
+func (g *ghash) block(b []byte) {
+	g.y.low ^= binary.BigEndian.Uint64(b)
+	g.y.high ^= binary.BigEndian.Uint64(b[8:])
+	g.mul(&g.y)
+}
+
+// reductionTable is the reduction of the four bits shifted out of a field element
+var reductionTable = []uint16{
+	0x0000, 0x1c20, 0x3840, 0x2460, 0x7080, 0x6ca0, 0x48c0, 0x54e0,
+	0xe100, 0xfd20, 0xd940, 0xc560, 0x9180, 0x8da0, 0xa9c0, 0xb5e0,
+}
+
+// mul sets y to y times the hash key
+func (g *ghash) mul(y *fieldElement) {
+	var z fieldElement
+	for i := 0; i < 2; i++ {
+		word := y.high
+		if i == 1 {
+			word = y.low
+		}
+
+		for j := 0; j < 64; j += 4 {
+			msw := z.high & 0xf
+			z.high >>= 4
+			z.high |= z.low << 60
+			z.low >>= 4
+			z.low ^= uint64(reductionTable[msw]) << 48
+
+			t := &g.table[word&0xf]
+			z.low ^= t.low
+			z.high ^= t.high
+			word >>= 4
+		}
+	}
+	*y = z
+}
+
+// double multiplies a field element by x
+func double(x fieldElement) fieldElement {
+	msbSet := x.high&1 == 1
+	d := fieldElement{low: x.low >> 1, high: x.high>>1 | x.low<<63}
+	if msbSet {
+		d.low ^= 0xe100000000000000
+	}
+	return d
+}
+
+// reverseBits reverses the order of the four low bits of i
+func reverseBits(i int) int {
+	i = ((i << 2) & 0xc) | ((i >> 2) & 0x3)
+	i = ((i << 1) & 0xa) | ((i >> 1) & 0x5)
+	return i
+}
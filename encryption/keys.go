@@ -0,0 +1,162 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Key wrapping algorithms
+const (
+	WrapAESGCM  = "AES/GCM"
+	WrapAESWrap = "AESWrap"
+	WrapKMS     = "kms"
+)
+
+// KeyProvider unwraps the data keys of encrypted objects.
+// Implement it to plug in other key sources such as a KMS.
+type KeyProvider interface {
+	// UnwrapKey decrypts the wrapped content encryption key in the envelope
+	UnwrapKey(ctx context.Context, env *Envelope) ([]byte, error)
+}
+
+// MasterKeyProvider unwraps data keys with a locally held AES master key
+type MasterKeyProvider struct {
+	key []byte
+}
+
+// NewMasterKeyProvider creates a key provider for a 128, 192 or 256-bit AES master key
+func NewMasterKeyProvider(key []byte) (*MasterKeyProvider, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("master key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+	return &MasterKeyProvider{key: key}, nil
+}
+
+// UnwrapKey decrypts the wrapped content encryption key in the envelope
+func (m *MasterKeyProvider) UnwrapKey(ctx context.Context, env *Envelope) ([]byte, error) {
+	block, err := aes.NewCipher(m.key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch env.WrapAlgorithm {
+	case WrapAESGCM:
+		// The nonce is prepended to the wrapped key, and the content algorithm is authenticated with it
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if len(env.WrappedKey) < gcm.NonceSize() {
+			return nil, errors.New("wrapped key too short")
+		}
+		nonce, wrapped := env.WrappedKey[:gcm.NonceSize()], env.WrappedKey[gcm.NonceSize():]
+		key, err := gcm.Open(nil, nonce, wrapped, []byte(env.ContentAlgorithm))
+		if err != nil {
+			return nil, errors.New("wrong master key or corrupted wrapped key")
+		}
+		return key, nil
+	case WrapAESWrap:
+		return aesKeyUnwrap(block, env.WrappedKey)
+	case "":
+		// The V1 format wraps the key with AES in ECB mode with PKCS#5 padding
+		return ecbUnwrap(block, env.WrappedKey)
+	case WrapKMS, "kms+context":
+		return nil, fmt.Errorf("data key is wrapped with KMS; a KMS key provider is required")
+	default:
+		return nil, fmt.Errorf("unsupported key wrapping algorithm %q", env.WrapAlgorithm)
+	}
+}
+
+// aesKeyUnwrap implements the RFC 3394 AES key unwrap algorithm
+func aesKeyUnwrap(block cipher.Block, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, errors.New("invalid wrapped key length")
+	}
+
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, n*8)
+	copy(r, wrapped[8:])
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^t)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+
+	// The default initial value from RFC 3394
+	iv := []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+	if subtle.ConstantTimeCompare(a, iv) != 1 {
+		return nil, errors.New("wrong master key or corrupted wrapped key")
+	}
+
+	return r, nil
+}
+
+// ecbUnwrap decrypts a key wrapped with AES/ECB/PKCS5Padding
+func ecbUnwrap(block cipher.Block, wrapped []byte) ([]byte, error) {
+	if len(wrapped) == 0 || len(wrapped)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid wrapped key length")
+	}
+
+	key := make([]byte, len(wrapped))
+	for i := 0; i < len(wrapped); i += aes.BlockSize {
+		block.Decrypt(key[i:i+aes.BlockSize], wrapped[i:i+aes.BlockSize])
+	}
+
+	unpadded, err := unpad(key)
+	if err != nil {
+		return nil, errors.New("wrong master key or corrupted wrapped key")
+	}
+	return unpadded, nil
+}
+
+// LoadKey reads a key from a file, or from an environment variable if no file is given.
+// The value may be the raw key bytes or their base64 encoding.
+func LoadKey(file, env string) ([]byte, error) {
+	var raw []byte
+	switch {
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading key file: %w", err)
+		}
+		raw = data
+	case env != "":
+		value := os.Getenv(env)
+		if value == "" {
+			return nil, fmt.Errorf("key environment variable %s is not set", env)
+		}
+		raw = []byte(value)
+	default:
+		return nil, errors.New("no key file or environment variable given")
+	}
+
+	switch len(raw) {
+	case 16, 24, 32:
+		return raw, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("key must be raw bytes or base64 encoded: %w", err)
+	}
+	return decoded, nil
+}
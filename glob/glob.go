@@ -0,0 +1,205 @@
+package glob
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// metaChars are the characters with a special meaning in a pattern
+const metaChars = "*?[{"
+
+// Pattern is a compiled glob pattern for slash-separated keys.
+//
+//   - "*" matches any run of characters except /
+//   - "**" matches any run of characters, including /; "**/" also matches no directories at all
+//   - "?" matches one character except /
+//   - "[abc]" matches one character in the class; "[!abc]" one character not in it.
+//     Classes never match /, and ranges such as "[a-z]" may be used; "^" has no special meaning.
+//   - "{a,b}" matches any of the comma-separated alternatives
+//
+// A nil *Pattern matches every key.
+type Pattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// HasMeta reports whether s contains any pattern characters
+func HasMeta(s string) bool {
+	return strings.ContainsAny(s, metaChars)
+}
+
+// Split divides a path into the literal directory before the first segment containing
+// pattern characters and the pattern that follows it. Paths without pattern characters
+// are returned whole with an empty pattern.
+func Split(path string) (dir, pattern string) {
+	i := strings.IndexAny(path, metaChars)
+	if i < 0 {
+		return path, ""
+	}
+
+	dir = path[:strings.LastIndex(path[:i], "/")+1]
+	return dir, path[len(dir):]
+}
+
+// Compile parses a glob pattern
+func Compile(pattern string) (*Pattern, error) {
+	var re strings.Builder
+	re.WriteString("^")
+
+	inAlternatives := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					re.WriteString("(?:.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid pattern %q: unterminated [", pattern)
+			}
+			class, err := classRegexp(pattern[i+1 : i+1+end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			re.WriteString(class)
+			i += end + 1
+		case '{':
+			if inAlternatives {
+				return nil, fmt.Errorf("invalid pattern %q: nested {", pattern)
+			}
+			inAlternatives = true
+			re.WriteString("(?:")
+		case '}':
+			if !inAlternatives {
+				return nil, fmt.Errorf("invalid pattern %q: unmatched }", pattern)
+			}
+			inAlternatives = false
+			re.WriteString(")")
+		case ',':
+			if inAlternatives {
+				re.WriteString("|")
+			} else {
+				re.WriteString(",")
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if inAlternatives {
+		return nil, fmt.Errorf("invalid pattern %q: unterminated {", pattern)
+	}
+
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	return &Pattern{pattern: pattern, re: compiled}, nil
+}
+
+// noChar is a regular expression class that matches nothing, for classes holding only /
+const noChar = `[^\x00-\x{10FFFF}]`
+
+// classRegexp translates the body of a [...] class into a regular expression class.
+// Like * and ?, a class never matches /, and only a leading ! negates it; every other
+// character, ^ included, stands for itself.
+func classRegexp(class string) (string, error) {
+	negate := strings.HasPrefix(class, "!")
+	if negate {
+		class = class[1:]
+	}
+	chars := []rune(class)
+	if len(chars) == 0 {
+		return "", errors.New("empty character class")
+	}
+
+	var re strings.Builder
+	re.WriteString("[")
+	if negate {
+		re.WriteString("^/")
+	}
+	writeRange := func(lo, hi rune) {
+		// Every bound is written as a code point, so no character needs escaping
+		fmt.Fprintf(&re, `\x{%x}`, lo)
+		if hi != lo {
+			fmt.Fprintf(&re, `-\x{%x}`, hi)
+		}
+	}
+
+	ranges := 0
+	for i := 0; i < len(chars); i++ {
+		lo, hi := chars[i], chars[i]
+		if i+2 < len(chars) && chars[i+1] == '-' {
+			hi = chars[i+2]
+			i += 2
+		}
+		if lo > hi {
+			return "", fmt.Errorf("invalid range %c-%c in character class", lo, hi)
+		}
+
+		// A negated class already excludes /; otherwise / is cut out of the range
+		if !negate && lo <= '/' && '/' <= hi {
+			if lo < '/' {
+				writeRange(lo, '/'-1)
+				ranges++
+			}
+			if hi > '/' {
+				writeRange('/'+1, hi)
+				ranges++
+			}
+			continue
+		}
+		writeRange(lo, hi)
+		ranges++
+	}
+	re.WriteString("]")
+
+	if !negate && ranges == 0 {
+		return noChar, nil
+	}
+	return re.String(), nil
+}
+
+// Match reports whether name matches the pattern
+func (p *Pattern) Match(name string) bool {
+	if p == nil {
+		return true
+	}
+	return p.re.MatchString(name)
+}
+
+// LiteralPrefix returns the part of the pattern before the first pattern character.
+// Every matching name starts with it, so it can narrow a listing.
+func (p *Pattern) LiteralPrefix() string {
+	if p == nil {
+		return ""
+	}
+	if i := strings.IndexAny(p.pattern, metaChars); i >= 0 {
+		return p.pattern[:i]
+	}
+	return p.pattern
+}
+
+// String returns the pattern as written
+func (p *Pattern) String() string {
+	if p == nil {
+		return ""
+	}
+	return p.pattern
+}
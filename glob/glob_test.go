@@ -0,0 +1,109 @@
+package glob
+
+import "testing"
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"*.log", []string{"a.log", ".log"}, []string{"a/b.log", "a.txt"}},
+		{"logs/**/*.gz", []string{"logs/a.gz", "logs/x/y/a.gz"}, []string{"logs/a.txt", "other/a.gz"}},
+		{"**", []string{"", "a", "a/b/c"}, nil},
+		{"a?c", []string{"abc", "a.c"}, []string{"a/c", "ac", "abbc"}},
+		{"[abc].txt", []string{"a.txt", "c.txt"}, []string{"d.txt", "/.txt"}},
+		{"[a-c]x", []string{"bx"}, []string{"dx", "-x"}},
+		{"[!abc].txt", []string{"d.txt"}, []string{"a.txt", "/.txt"}},
+		{"x[a/b]y", []string{"xay", "xby"}, []string{"x/y"}},
+		{"x[!a]y", []string{"xby"}, []string{"x/y", "xay"}},
+		{"x[+-0]y", []string{"x+y", "x.y", "x0y"}, []string{"x/y"}},
+		{"x[/]y", nil, []string{"x/y", "xay"}},
+		{"[^a]", []string{"^", "a"}, []string{"b"}},
+		{"[a^]", []string{"^", "a"}, []string{"b"}},
+		{`[\]`, []string{`\`}, []string{"a"}},
+		{"[.]", []string{"."}, []string{"a"}},
+		{"*.{jpg,png}", []string{"a.jpg", "a.png"}, []string{"a.gif", "a.{jpg,png}"}},
+		{"a,b", []string{"a,b"}, []string{"a"}},
+		{"a+b(c)", []string{"a+b(c)"}, []string{"aab(c)"}},
+	}
+
+	for _, tt := range tests {
+		p, err := Compile(tt.pattern)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.pattern, err)
+			continue
+		}
+		for _, name := range tt.match {
+			if !p.Match(name) {
+				t.Errorf("%q should match %q", tt.pattern, name)
+			}
+		}
+		for _, name := range tt.noMatch {
+			if p.Match(name) {
+				t.Errorf("%q should not match %q", tt.pattern, name)
+			}
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, pattern := range []string{"[abc", "[]", "[!]", "[z-a]", "{a,b", "a}", "{a,{b}}"} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Compile(%q): expected an error", pattern)
+		}
+	}
+}
+
+func TestNilPatternMatchesEverything(t *testing.T) {
+	var p *Pattern
+	if !p.Match("any/key") || p.LiteralPrefix() != "" || p.String() != "" {
+		t.Error("a nil pattern should match every key and have no prefix")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		path, dir, pattern string
+	}{
+		{"logs/2026/", "logs/2026/", ""},
+		{"logs/*.gz", "logs/", "*.gz"},
+		{"logs/2026-*/host/*.gz", "logs/", "2026-*/host/*.gz"},
+		{"*.gz", "", "*.gz"},
+		{"a/b/c[0-9]/d", "a/b/", "c[0-9]/d"},
+		{"a/{x,y}/z", "a/", "{x,y}/z"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		dir, pattern := Split(tt.path)
+		if dir != tt.dir || pattern != tt.pattern {
+			t.Errorf("Split(%q) = %q, %q; want %q, %q", tt.path, dir, pattern, tt.dir, tt.pattern)
+		}
+	}
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	tests := []struct {
+		pattern, prefix string
+	}{
+		{"logs/2026-*/x", "logs/2026-"},
+		{"a?", "a"},
+		{"[ab]", ""},
+		{"x{a,b}", "x"},
+		{"plain/key", "plain/key"},
+	}
+
+	for _, tt := range tests {
+		p, err := Compile(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.LiteralPrefix(); got != tt.prefix {
+			t.Errorf("LiteralPrefix(%q) = %q, want %q", tt.pattern, got, tt.prefix)
+		}
+		if !HasMeta(tt.pattern) != (tt.prefix == tt.pattern) {
+			t.Errorf("HasMeta(%q) disagrees with LiteralPrefix", tt.pattern)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Package localfiles lists the regular files under a local directory
+package localfiles
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// File is a regular file found under a directory
+type File struct {
+	// Path is the file's path on disk
+	Path string
+	// Name is the slash-separated path relative to the directory, as object keys are written
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// List returns the regular files under dir in lexical order. Directories, symlinks and
+// other special files are left out. A dir that does not exist yet holds no files.
+func List(dir string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, File{
+			Path:    p,
+			Name:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local files: %w", err)
+	}
+
+	return files, nil
+}
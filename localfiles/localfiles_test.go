@@ -0,0 +1,66 @@
+package localfiles
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"b.txt":     "bb",
+		"a/one.txt": "1",
+		"a/b/c.txt": "ccc",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b.txt", filepath.Join(dir, "link.txt")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	files, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	sizes := make(map[string]int64)
+	for _, file := range files {
+		names = append(names, file.Name)
+		sizes[file.Name] = file.Size
+		if want := filepath.Join(dir, filepath.FromSlash(file.Name)); file.Path != want {
+			t.Errorf("%s: path %s, want %s", file.Name, file.Path, want)
+		}
+		if file.ModTime.IsZero() {
+			t.Errorf("%s: no modification time", file.Name)
+		}
+	}
+
+	if want := []string{"a/b/c.txt", "a/one.txt", "b.txt"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %v, want %v", names, want)
+	}
+	if want := map[string]int64{"a/b/c.txt": 3, "a/one.txt": 1, "b.txt": 2}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("sizes %v, want %v", sizes, want)
+	}
+}
+
+func TestListMissingDirectory(t *testing.T) {
+	files, err := List(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("got %v, want no files", files)
+	}
+}
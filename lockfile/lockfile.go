@@ -0,0 +1,197 @@
+package lockfile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"download-file-from-bucket/providers"
+)
+
+// DefaultFilename is the lockfile name used when none is given
+const DefaultFilename = "bucket.lock"
+
+// formatVersion is the version of the lockfile format written by this package
+const formatVersion = 1
+
+// Lockfile pins the exact set of objects under a prefix so a clone can be reproduced
+type Lockfile struct {
+	Version   int       `json:"version"`
+	Source    string    `json:"source"`
+	Bucket    string    `json:"bucket"`
+	Prefix    string    `json:"prefix"`
+	CreatedAt time.Time `json:"created_at"`
+	Entries   []Entry   `json:"objects"`
+}
+
+// Entry records a single pinned object
+type Entry struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"etag"`
+	VersionID string `json:"version_id,omitempty"`
+}
+
+// New creates a lockfile for the given source from a set of objects
+func New(source, bucket, prefix string, objects []providers.Object) *Lockfile {
+	entries := make([]Entry, 0, len(objects))
+	for _, obj := range objects {
+		entries = append(entries, Entry{
+			Key:       obj.Key,
+			Size:      obj.Size,
+			ETag:      obj.ETag,
+			VersionID: obj.VersionID,
+		})
+	}
+
+	// Sort by key so the same dataset always produces the same file
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	return &Lockfile{
+		Version:   formatVersion,
+		Source:    source,
+		Bucket:    bucket,
+		Prefix:    prefix,
+		CreatedAt: time.Now().UTC(),
+		Entries:   entries,
+	}
+}
+
+// Load reads a lockfile from disk
+func Load(filename string) (*Lockfile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading lockfile: %w", err)
+	}
+
+	var lock Lockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("error parsing lockfile %s: %w", filename, err)
+	}
+
+	if lock.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported lockfile version %d in %s", lock.Version, filename)
+	}
+	if lock.Source == "" {
+		return nil, fmt.Errorf("lockfile %s has no source", filename)
+	}
+
+	return &lock, nil
+}
+
+// Save writes the lockfile to disk
+func (l *Lockfile) Save(filename string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling lockfile: %w", err)
+	}
+
+	if err := os.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing lockfile: %w", err)
+	}
+
+	return nil
+}
+
+// Objects returns the pinned objects in a form the downloader accepts
+func (l *Lockfile) Objects() []providers.Object {
+	objects := make([]providers.Object, 0, len(l.Entries))
+	for _, e := range l.Entries {
+		objects = append(objects, providers.Object{
+			Key:       e.Key,
+			Size:      e.Size,
+			ETag:      e.ETag,
+			VersionID: e.VersionID,
+		})
+	}
+	return objects
+}
+
+// Check verifies that every pinned object can still be fetched exactly as recorded.
+// Objects with a version ID are checked against that version; the rest must be unchanged.
+// Up to concurrency objects are checked at once, and the check stops at the first request that fails.
+func (l *Lockfile) Check(ctx context.Context, provider providers.Provider, concurrency int) error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changed := make([]bool, len(l.Entries))
+	indexes := make(chan int, len(l.Entries))
+	for i := range l.Entries {
+		indexes <- i
+	}
+	close(indexes)
+
+	var mu sync.Mutex
+	var firstErr error
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				e := l.Entries[i]
+				info, err := e.fetchInfo(ctx, provider)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("failed to check %s against lockfile: %w", e.Key, err)
+						cancel()
+					}
+					mu.Unlock()
+					continue
+				}
+
+				changed[i] = !e.Matches(*info)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var keys []string
+	for i, e := range l.Entries {
+		if changed[i] {
+			keys = append(keys, e.Key)
+		}
+	}
+	if len(keys) > 0 {
+		return fmt.Errorf("%d objects changed since the lockfile was written: %s", len(keys), strings.Join(keys, ", "))
+	}
+
+	return nil
+}
+
+// fetchInfo gets the current metadata of the pinned object, at its version if it has one
+func (e Entry) fetchInfo(ctx context.Context, provider providers.Provider) (*providers.Object, error) {
+	if e.VersionID != "" {
+		return provider.GetObjectVersionInfo(ctx, e.Key, e.VersionID)
+	}
+	return provider.GetObjectInfo(ctx, e.Key)
+}
+
+// Matches reports whether an object is identical to the pinned entry
+func (e Entry) Matches(obj providers.Object) bool {
+	return obj.Size == e.Size && obj.ETag == e.ETag
+}
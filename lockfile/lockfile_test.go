@@ -0,0 +1,103 @@
+package lockfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"download-file-from-bucket/providers"
+)
+
+// headProvider answers HEAD requests from a map of objects and records how many run at once
+type headProvider struct {
+	providers.Provider
+	objects map[string]providers.Object
+
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (p *headProvider) GetObjectInfo(ctx context.Context, key string) (*providers.Object, error) {
+	p.mu.Lock()
+	p.running++
+	if p.running > p.peak {
+		p.peak = p.running
+	}
+	p.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	p.mu.Lock()
+	p.running--
+	p.mu.Unlock()
+
+	obj, ok := p.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, providers.ErrNotFound)
+	}
+	return &obj, nil
+}
+
+func (p *headProvider) GetObjectVersionInfo(ctx context.Context, key, versionID string) (*providers.Object, error) {
+	return p.GetObjectInfo(ctx, key)
+}
+
+func testLock(n int) (*Lockfile, map[string]providers.Object) {
+	objects := make(map[string]providers.Object)
+	var list []providers.Object
+	for i := 0; i < n; i++ {
+		obj := providers.Object{Key: fmt.Sprintf("data/%03d", i), Size: int64(i), ETag: fmt.Sprintf(`"%d"`, i)}
+		objects[obj.Key] = obj
+		list = append(list, obj)
+	}
+	return New("s3://bucket/data/", "bucket", "data/", list), objects
+}
+
+func TestCheck(t *testing.T) {
+	lock, objects := testLock(40)
+	provider := &headProvider{objects: objects}
+
+	if err := lock.Check(context.Background(), provider, 8); err != nil {
+		t.Fatalf("Check() = %v, want nil", err)
+	}
+	if provider.peak < 2 || provider.peak > 8 {
+		t.Errorf("peak concurrency = %d, want between 2 and 8", provider.peak)
+	}
+}
+
+func TestCheckReportsChangedObjects(t *testing.T) {
+	lock, objects := testLock(10)
+	changed := objects["data/003"]
+	changed.ETag = `"other"`
+	objects["data/003"] = changed
+
+	err := lock.Check(context.Background(), &headProvider{objects: objects}, 4)
+	if err == nil || !strings.Contains(err.Error(), "1 objects changed") || !strings.Contains(err.Error(), "data/003") {
+		t.Fatalf("Check() = %v, want data/003 reported as changed", err)
+	}
+}
+
+func TestCheckStopsAtFailedRequest(t *testing.T) {
+	lock, objects := testLock(10)
+	delete(objects, "data/005")
+
+	err := lock.Check(context.Background(), &headProvider{objects: objects}, 4)
+	if !errors.Is(err, providers.ErrNotFound) || !strings.Contains(err.Error(), "data/005") {
+		t.Fatalf("Check() = %v, want not found for data/005", err)
+	}
+}
+
+func TestCheckCancelled(t *testing.T) {
+	lock, objects := testLock(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := lock.Check(ctx, &headProvider{objects: objects}, 4); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Check() = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,137 @@
+// Package metrics records Prometheus metrics for downloads and provider requests
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "download_bucket"
+
+// Registry holds every metric of the tool
+var Registry = prometheus.NewRegistry()
+
+var (
+	bytesDownloaded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "downloaded_bytes_total",
+		Help:      "Bytes of objects downloaded successfully.",
+	})
+
+	objects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "objects_total",
+		Help:      "Objects handled by downloads, by result (completed, failed or skipped).",
+	}, []string{"result"})
+
+	activeWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_workers",
+		Help:      "Workers currently downloading an object.",
+	})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Latency of provider requests, including retries, by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	requestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_request_errors_total",
+		Help:      "Provider requests that failed after any retries, by operation.",
+	}, []string{"operation"})
+
+	retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_retries_total",
+		Help:      "Provider requests retried, by operation.",
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		bytesDownloaded,
+		objects,
+		activeWorkers,
+		requestDuration,
+		requestErrors,
+		retries,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// ObjectCompleted records an object downloaded successfully
+func ObjectCompleted(size int64) {
+	objects.WithLabelValues("completed").Inc()
+	bytesDownloaded.Add(float64(size))
+}
+
+// ObjectFailed records an object that could not be downloaded
+func ObjectFailed() {
+	objects.WithLabelValues("failed").Inc()
+}
+
+// ObjectsSkipped records objects left out by filters or storage class
+func ObjectsSkipped(n int) {
+	objects.WithLabelValues("skipped").Add(float64(n))
+}
+
+// WorkerStarted records a worker starting to download an object; call the returned function when it is done
+func WorkerStarted() func() {
+	activeWorkers.Inc()
+	return activeWorkers.Dec
+}
+
+// ObserveRequest records a finished provider request.
+// Operations are short names such as "list", "get" and "head".
+func ObserveRequest(operation string, duration time.Duration, retryCount int, err error) {
+	requestDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if retryCount > 0 {
+		retries.WithLabelValues(operation).Add(float64(retryCount))
+	}
+	if err != nil {
+		requestErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Serve exposes the metrics on addr at /metrics in the background.
+// The returned server should be closed when the process is done.
+func Serve(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+
+	return server, nil
+}
+
+// WriteTextfile writes the current metrics to path, in the format read by the node
+// exporter's textfile collector and accepted by a Pushgateway
+func WriteTextfile(path string) error {
+	if err := prometheus.WriteToTextfile(path, Registry); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObjectMetrics(t *testing.T) {
+	completed := testutil.ToFloat64(objects.WithLabelValues("completed"))
+	failed := testutil.ToFloat64(objects.WithLabelValues("failed"))
+	skipped := testutil.ToFloat64(objects.WithLabelValues("skipped"))
+	bytes := testutil.ToFloat64(bytesDownloaded)
+
+	ObjectCompleted(100)
+	ObjectCompleted(50)
+	ObjectFailed()
+	ObjectsSkipped(3)
+
+	if got := testutil.ToFloat64(objects.WithLabelValues("completed")) - completed; got != 2 {
+		t.Errorf("completed objects rose by %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(objects.WithLabelValues("failed")) - failed; got != 1 {
+		t.Errorf("failed objects rose by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(objects.WithLabelValues("skipped")) - skipped; got != 3 {
+		t.Errorf("skipped objects rose by %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(bytesDownloaded) - bytes; got != 150 {
+		t.Errorf("downloaded bytes rose by %v, want 150", got)
+	}
+
+	done := WorkerStarted()
+	if got := testutil.ToFloat64(activeWorkers); got != 1 {
+		t.Errorf("active workers = %v, want 1", got)
+	}
+	done()
+	if got := testutil.ToFloat64(activeWorkers); got != 0 {
+		t.Errorf("active workers = %v after the worker finished, want 0", got)
+	}
+}
+
+func TestObserveRequest(t *testing.T) {
+	ObserveRequest("test-get", 20*time.Millisecond, 0, nil)
+	ObserveRequest("test-get", 30*time.Millisecond, 2, errors.New("throttled"))
+
+	if got := testutil.ToFloat64(retries.WithLabelValues("test-get")); got != 2 {
+		t.Errorf("retries = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(requestErrors.WithLabelValues("test-get")); got != 1 {
+		t.Errorf("errors = %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(requestDuration, namespace+"_provider_request_duration_seconds"); got < 1 {
+		t.Errorf("request durations not collected")
+	}
+}
+
+func TestHandlerServesMetrics(t *testing.T) {
+	ObserveRequest("test-list", time.Millisecond, 0, nil)
+	ObjectsSkipped(0)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`download_bucket_provider_request_duration_seconds_count{operation="test-list"} 1`,
+		"download_bucket_objects_total",
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not include %q", want)
+		}
+	}
+}
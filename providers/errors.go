@@ -0,0 +1,40 @@
+package providers
+
+import "errors"
+
+// Kinds of provider failure. Errors returned by providers match one of these with
+// errors.Is when the cause is known, so callers can decide whether to retry.
+var (
+	// ErrNotFound means the bucket, object or version does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrAccessDenied means the credentials are missing, invalid or lack permission
+	ErrAccessDenied = errors.New("access denied")
+	// ErrThrottled means the provider asked to slow down; retrying later may succeed
+	ErrThrottled = errors.New("throttled")
+	// ErrInvalidState means the object cannot be read as it is, such as an archived object that is not restored
+	ErrInvalidState = errors.New("invalid object state")
+	// ErrNetwork means the provider could not be reached or the connection failed
+	ErrNetwork = errors.New("network error")
+)
+
+// IsTransient reports whether err is a failure that retrying later may fix
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrThrottled) || errors.Is(err, ErrNetwork)
+}
+
+// Error is a provider failure classified by kind. Its message is that of the
+// underlying error, which stays available to errors.As.
+type Error struct {
+	// Kind is one of the Err* values, or context.Canceled for cancelled requests
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap exposes both the kind and the underlying error
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
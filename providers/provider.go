@@ -3,6 +3,8 @@ package providers
 import (
 	"context"
 	"io"
+	"log/slog"
+	"strings"
 	"time"
 )
 
@@ -11,24 +13,104 @@ type Provider interface {
 	// ListObjects lists all objects with the given prefix
 	ListObjects(ctx context.Context, prefix string) ([]Object, error)
 	
+	// ListObjectVersions lists every version and delete marker of the objects with the given prefix
+	ListObjectVersions(ctx context.Context, prefix string) ([]Object, error)
+	
 	// DownloadObject downloads a specific object
 	DownloadObject(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// DownloadObjectVersion downloads a specific version of an object
+	DownloadObjectVersion(ctx context.Context, key, versionID string) (io.ReadCloser, error)
 	
 	// GetObjectInfo gets metadata about an object
 	GetObjectInfo(ctx context.Context, key string) (*Object, error)
+
+	// GetObjectVersionInfo gets metadata about a specific version of an object
+	GetObjectVersionInfo(ctx context.Context, key, versionID string) (*Object, error)
+
+	// GetObjectTags gets the tags of an object; an empty versionID selects the current version
+	GetObjectTags(ctx context.Context, key, versionID string) (map[string]string, error)
 	
+	// RestoreObject requests a temporary restored copy of an archived object, kept for the given number of days
+	RestoreObject(ctx context.Context, key string, days int64, tier string) error
+
+	// UploadObject writes the content of r to key, replacing any existing object
+	UploadObject(ctx context.Context, key string, r io.Reader, opts UploadOptions) error
+
 	// Close cleans up any resources used by the provider
 	Close() error
 }
 
 // Object represents a cloud storage object
 type Object struct {
-	Key          string            `json:"key"`
-	Size         int64             `json:"size"`
-	LastModified time.Time         `json:"last_modified"`
-	ETag         string            `json:"etag"`
-	ContentType  string            `json:"content_type"`
-	Metadata     map[string]string `json:"metadata"`
+	Key             string            `json:"key"`
+	Size            int64             `json:"size"`
+	LastModified    time.Time         `json:"last_modified"`
+	ETag            string            `json:"etag"`
+	VersionID       string            `json:"version_id,omitempty"`
+	IsLatest        bool              `json:"is_latest,omitempty"`
+	DeleteMarker    bool              `json:"delete_marker,omitempty"`
+	StorageClass    string            `json:"storage_class,omitempty"`
+	Restore         RestoreStatus     `json:"restore,omitempty"`
+	RestoreExpiry   time.Time         `json:"restore_expiry,omitempty"`
+	ContentType     string            `json:"content_type"`
+	ContentEncoding string            `json:"content_encoding,omitempty"`
+	Metadata        map[string]string `json:"metadata"`
+	// Encryption is the server-side encryption of the object, such as AES256 or aws:kms,
+	// or SSE-C for a customer-provided key. Listings leave it empty.
+	Encryption string `json:"encryption,omitempty"`
+}
+
+// ObjectBody is the content of a downloaded object along with the properties returned with it.
+// Providers that learn an object's properties while downloading it return one from
+// DownloadObject and DownloadObjectVersion, sparing callers a separate metadata request.
+type ObjectBody struct {
+	io.ReadCloser
+	Info Object
+}
+
+// UploadOptions holds the properties stored with an uploaded object
+type UploadOptions struct {
+	ContentType     string
+	ContentEncoding string
+	Metadata        map[string]string
+	// PartSize is the size of each part when the object is uploaded in parts; zero uses the provider default
+	PartSize int64
+}
+
+// RestoreStatus describes the state of the restored copy of an archived object
+type RestoreStatus string
+
+const (
+	RestoreNone       RestoreStatus = ""
+	RestoreInProgress RestoreStatus = "in-progress"
+	RestoreCompleted  RestoreStatus = "restored"
+)
+
+// Storage classes whose objects must be restored before they can be downloaded
+const (
+	StorageClassGlacier     = "GLACIER"
+	StorageClassDeepArchive = "DEEP_ARCHIVE"
+)
+
+// IsArchived reports whether the object is stored in an archive storage class
+func (o Object) IsArchived() bool {
+	return o.StorageClass == StorageClassGlacier || o.StorageClass == StorageClassDeepArchive
+}
+
+// EncryptionSSEC is the Encryption of objects encrypted with a customer-provided key
+const EncryptionSSEC = "SSE-C"
+
+// ETagIsMD5 reports whether the ETag is derived from the MD5 of the content, as it is for
+// unencrypted and SSE-S3 objects. The ETags of SSE-KMS and SSE-C objects are not, so files
+// cannot be checked against them.
+func (o Object) ETagIsMD5() bool {
+	return o.Encryption != EncryptionSSEC && !strings.HasPrefix(o.Encryption, "aws:kms")
+}
+
+// NeedsRestore reports whether the object is archived and has no restored copy available
+func (o Object) NeedsRestore() bool {
+	return o.IsArchived() && o.Restore != RestoreCompleted
 }
 
 // DownloadProgress represents the progress of a download operation
@@ -57,4 +139,10 @@ type ProviderOptions struct {
 	SecretKey string
 	Bucket    string
 	Options   map[string]string
-} 
\ No newline at end of file
+
+	// SSECustomerKey is the 256-bit key for objects encrypted with SSE-C, if any
+	SSECustomerKey []byte
+
+	// Logger receives a debug record for every request; defaults to slog.Default()
+	Logger *slog.Logger
+}
@@ -4,23 +4,45 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"download-file-from-bucket/metrics"
+	"download-file-from-bucket/tracing"
 )
 
 // S3Provider implements the Provider interface for AWS S3 and S3-compatible services
 type S3Provider struct {
-	client *s3.S3
-	bucket string
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
+	// sseCustomerKey is the raw SSE-C key sent with object reads and uploads, if set
+	sseCustomerKey string
+	logger         *slog.Logger
 }
 
 // NewS3Provider creates a new S3 provider
 func NewS3Provider(opts ProviderOptions) (*S3Provider, error) {
+	// Download objects byte for byte; Go's transport would otherwise silently
+	// gunzip objects stored with Content-Encoding: gzip
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableCompression = true
+
 	config := &aws.Config{
-		Region: aws.String(opts.Region),
+		Region:     aws.String(opts.Region),
+		HTTPClient: &http.Client{Transport: transport},
 	}
 
 	// Set custom endpoint for S3-compatible services (like DigitalOcean Spaces)
@@ -43,10 +65,76 @@ func NewS3Provider(opts ProviderOptions) (*S3Provider, error) {
 		return nil, fmt.Errorf("failed to create AWS session: %w", err)
 	}
 
-	return &S3Provider{
-		client: s3.New(sess),
-		bucket: opts.Bucket,
-	}, nil
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	client := s3.New(sess)
+	p := &S3Provider{
+		client:         client,
+		uploader:       s3manager.NewUploaderWithClient(client),
+		bucket:         opts.Bucket,
+		sseCustomerKey: string(opts.SSECustomerKey),
+		logger:         logger.With("bucket", opts.Bucket),
+	}
+	client.Handlers.Complete.PushBack(p.observeRequest)
+
+	return p, nil
+}
+
+// observeRequest records the latency, retries and outcome of a finished S3 request,
+// in metrics, in the log and as a span under the caller's span. Each page of a listing is a separate request.
+func (p *S3Provider) observeRequest(r *request.Request) {
+	duration := time.Since(r.Time)
+	metrics.ObserveRequest(operationName(r.Operation.Name), duration, r.RetryCount, r.Error)
+	logArgs := []any{"operation", r.Operation.Name, "duration", duration, "retries", r.RetryCount}
+	if r.Error != nil {
+		logArgs = append(logArgs, "error", r.Error)
+	}
+	p.logger.DebugContext(r.Context(), "s3 request", logArgs...)
+
+	attrs := []attribute.KeyValue{
+		attribute.String("s3.operation", r.Operation.Name),
+		attribute.Int("retries", r.RetryCount),
+	}
+	switch params := r.Params.(type) {
+	case *s3.ListObjectsV2Input:
+		attrs = append(attrs, attribute.String("bucket", aws.StringValue(params.Bucket)), attribute.String("prefix", aws.StringValue(params.Prefix)))
+	case *s3.ListObjectVersionsInput:
+		attrs = append(attrs, attribute.String("bucket", aws.StringValue(params.Bucket)), attribute.String("prefix", aws.StringValue(params.Prefix)))
+	case *s3.GetObjectInput:
+		attrs = append(attrs, attribute.String("bucket", aws.StringValue(params.Bucket)), attribute.String("key", aws.StringValue(params.Key)))
+	case *s3.HeadObjectInput:
+		attrs = append(attrs, attribute.String("bucket", aws.StringValue(params.Bucket)), attribute.String("key", aws.StringValue(params.Key)))
+	}
+	if r.HTTPResponse != nil && r.HTTPResponse.StatusCode != 0 {
+		attrs = append(attrs, attribute.Int("http.status_code", r.HTTPResponse.StatusCode))
+		if r.HTTPResponse.ContentLength >= 0 {
+			attrs = append(attrs, attribute.Int64("http.response_content_length", r.HTTPResponse.ContentLength))
+		}
+	}
+	tracing.Record(r.Context(), "s3."+r.Operation.Name, r.Time, r.Error, attrs...)
+}
+
+// operationName shortens an S3 API operation to the name used in metrics
+func operationName(name string) string {
+	switch name {
+	case "ListObjectsV2", "ListObjectVersions":
+		return "list"
+	case "GetObject":
+		return "get"
+	case "HeadObject":
+		return "head"
+	case "GetObjectTagging":
+		return "tagging"
+	case "RestoreObject":
+		return "restore"
+	case "PutObject", "CreateMultipartUpload", "UploadPart", "CompleteMultipartUpload", "AbortMultipartUpload":
+		return "upload"
+	default:
+		return strings.ToLower(name)
+	}
 }
 
 // ListObjects lists all objects with the given prefix
@@ -56,22 +144,76 @@ func (p *S3Provider) ListObjects(ctx context.Context, prefix string) ([]Object,
 	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(p.bucket),
 		Prefix: aws.String(prefix),
+		// Ask for restore status so archived objects can be handled without a HEAD per object
+		OptionalObjectAttributes: aws.StringSlice([]string{s3.OptionalObjectAttributesRestoreStatus}),
 	}
 
 	err := p.client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
 		for _, obj := range page.Contents {
-			objects = append(objects, Object{
+			object := Object{
 				Key:          aws.StringValue(obj.Key),
 				Size:         aws.Int64Value(obj.Size),
 				LastModified: aws.TimeValue(obj.LastModified),
 				ETag:         aws.StringValue(obj.ETag),
+				StorageClass: aws.StringValue(obj.StorageClass),
+			}
+			if rs := obj.RestoreStatus; rs != nil {
+				if aws.BoolValue(rs.IsRestoreInProgress) {
+					object.Restore = RestoreInProgress
+				} else if rs.RestoreExpiryDate != nil {
+					object.Restore = RestoreCompleted
+					object.RestoreExpiry = aws.TimeValue(rs.RestoreExpiryDate)
+				}
+			}
+			objects = append(objects, object)
+		}
+		return !lastPage
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", classifyError(err))
+	}
+
+	return objects, nil
+}
+
+// ListObjectVersions lists every version and delete marker of the objects with the given prefix
+func (p *S3Provider) ListObjectVersions(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(p.bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	// Objects written before versioning was enabled have the version ID "null", which is kept
+	// as it is: S3 accepts it when fetching that version, while no version ID would fetch the latest
+	err := p.client.ListObjectVersionsPagesWithContext(ctx, input, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, v := range page.Versions {
+			objects = append(objects, Object{
+				Key:          aws.StringValue(v.Key),
+				Size:         aws.Int64Value(v.Size),
+				LastModified: aws.TimeValue(v.LastModified),
+				ETag:         aws.StringValue(v.ETag),
+				VersionID:    aws.StringValue(v.VersionId),
+				StorageClass: aws.StringValue(v.StorageClass),
+				IsLatest:     aws.BoolValue(v.IsLatest),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			objects = append(objects, Object{
+				Key:          aws.StringValue(m.Key),
+				LastModified: aws.TimeValue(m.LastModified),
+				VersionID:    aws.StringValue(m.VersionId),
+				IsLatest:     aws.BoolValue(m.IsLatest),
+				DeleteMarker: true,
 			})
 		}
 		return !lastPage
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to list objects: %w", err)
+		return nil, fmt.Errorf("failed to list object versions: %w", classifyError(err))
 	}
 
 	return objects, nil
@@ -83,13 +225,37 @@ func (p *S3Provider) DownloadObject(ctx context.Context, key string) (io.ReadClo
 		Bucket: aws.String(p.bucket),
 		Key:    aws.String(key),
 	}
+	if p.sseCustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		input.SSECustomerKey = aws.String(p.sseCustomerKey)
+	}
+
+	result, err := p.client.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object %s: %w", key, p.objectError(err))
+	}
+
+	return &ObjectBody{ReadCloser: result.Body, Info: *objectFromGet(key, result)}, nil
+}
+
+// DownloadObjectVersion downloads a specific version of an object
+func (p *S3Provider) DownloadObjectVersion(ctx context.Context, key, versionID string) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket:    aws.String(p.bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	}
+	if p.sseCustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		input.SSECustomerKey = aws.String(p.sseCustomerKey)
+	}
 
 	result, err := p.client.GetObjectWithContext(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download object %s: %w", key, err)
+		return nil, fmt.Errorf("failed to download object %s (version %s): %w", key, versionID, p.objectError(err))
 	}
 
-	return result.Body, nil
+	return &ObjectBody{ReadCloser: result.Body, Info: *objectFromGet(key, result)}, nil
 }
 
 // GetObjectInfo gets metadata about an object
@@ -98,25 +264,272 @@ func (p *S3Provider) GetObjectInfo(ctx context.Context, key string) (*Object, er
 		Bucket: aws.String(p.bucket),
 		Key:    aws.String(key),
 	}
+	if p.sseCustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		input.SSECustomerKey = aws.String(p.sseCustomerKey)
+	}
 
 	result, err := p.client.HeadObjectWithContext(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get object info for %s: %w", key, err)
+		return nil, fmt.Errorf("failed to get object info for %s: %w", key, p.objectError(err))
+	}
+
+	return objectFromHead(key, result), nil
+}
+
+// GetObjectVersionInfo gets metadata about a specific version of an object
+func (p *S3Provider) GetObjectVersionInfo(ctx context.Context, key, versionID string) (*Object, error) {
+	input := &s3.HeadObjectInput{
+		Bucket:    aws.String(p.bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	}
+	if p.sseCustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		input.SSECustomerKey = aws.String(p.sseCustomerKey)
+	}
+
+	result, err := p.client.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object info for %s (version %s): %w", key, versionID, p.objectError(err))
+	}
+
+	return objectFromHead(key, result), nil
+}
+
+// GetObjectTags gets the tags of an object, at a specific version if versionID is not empty
+func (p *S3Provider) GetObjectTags(ctx context.Context, key, versionID string) (map[string]string, error) {
+	input := &s3.GetObjectTaggingInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(key),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+
+	result, err := p.client.GetObjectTaggingWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags for %s: %w", key, p.objectError(err))
+	}
+
+	tags := make(map[string]string, len(result.TagSet))
+	for _, tag := range result.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
 	}
 
+	return tags, nil
+}
+
+// objectFromHead converts a HeadObject response into an Object
+func objectFromHead(key string, result *s3.HeadObjectOutput) *Object {
 	metadata := make(map[string]string)
 	for k, v := range result.Metadata {
 		metadata[k] = aws.StringValue(v)
 	}
 
+	restore, restoreExpiry := parseRestoreHeader(aws.StringValue(result.Restore))
+
+	encryption := aws.StringValue(result.ServerSideEncryption)
+	if result.SSECustomerAlgorithm != nil {
+		encryption = EncryptionSSEC
+	}
+
 	return &Object{
-		Key:          key,
-		Size:         aws.Int64Value(result.ContentLength),
-		LastModified: aws.TimeValue(result.LastModified),
-		ETag:         aws.StringValue(result.ETag),
-		ContentType:  aws.StringValue(result.ContentType),
-		Metadata:     metadata,
-	}, nil
+		Key:             key,
+		Size:            aws.Int64Value(result.ContentLength),
+		LastModified:    aws.TimeValue(result.LastModified),
+		ETag:            aws.StringValue(result.ETag),
+		VersionID:       aws.StringValue(result.VersionId),
+		ContentType:     aws.StringValue(result.ContentType),
+		ContentEncoding: aws.StringValue(result.ContentEncoding),
+		Metadata:        metadata,
+		StorageClass:    aws.StringValue(result.StorageClass),
+		Restore:         restore,
+		RestoreExpiry:   restoreExpiry,
+		Encryption:      encryption,
+	}
+}
+
+// objectFromGet describes an object from the headers returned with its content
+func objectFromGet(key string, result *s3.GetObjectOutput) *Object {
+	return objectFromHead(key, &s3.HeadObjectOutput{
+		ContentLength:   result.ContentLength,
+		LastModified:    result.LastModified,
+		ETag:            result.ETag,
+		VersionId:       result.VersionId,
+		ContentType:     result.ContentType,
+		ContentEncoding: result.ContentEncoding,
+		Metadata:        result.Metadata,
+		StorageClass:    result.StorageClass,
+		Restore:         result.Restore,
+
+		ServerSideEncryption: result.ServerSideEncryption,
+		SSECustomerAlgorithm: result.SSECustomerAlgorithm,
+	})
+}
+
+// parseRestoreHeader parses an x-amz-restore header such as
+// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+func parseRestoreHeader(header string) (RestoreStatus, time.Time) {
+	if header == "" {
+		return RestoreNone, time.Time{}
+	}
+	if strings.Contains(header, `ongoing-request="true"`) {
+		return RestoreInProgress, time.Time{}
+	}
+
+	var expiry time.Time
+	if i := strings.Index(header, `expiry-date="`); i >= 0 {
+		value := header[i+len(`expiry-date="`):]
+		if end := strings.Index(value, `"`); end >= 0 {
+			expiry, _ = time.Parse(time.RFC1123, value[:end])
+		}
+	}
+
+	return RestoreCompleted, expiry
+}
+
+// RestoreObject requests a temporary restored copy of an archived object
+func (p *S3Provider) RestoreObject(ctx context.Context, key string, days int64, tier string) error {
+	input := &s3.RestoreObjectInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(key),
+		RestoreRequest: &s3.RestoreRequest{
+			Days: aws.Int64(days),
+			GlacierJobParameters: &s3.GlacierJobParameters{
+				Tier: aws.String(tier),
+			},
+		},
+	}
+
+	_, err := p.client.RestoreObjectWithContext(ctx, input)
+	if err != nil {
+		// A restore that is already running is what the caller asked for
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "RestoreAlreadyInProgress" {
+			return nil
+		}
+		return fmt.Errorf("failed to restore object %s: %w", key, classifyError(err))
+	}
+
+	return nil
+}
+
+// UploadObject writes the content of r to key, using a multipart upload for large objects
+func (p *S3Provider) UploadObject(ctx context.Context, key string, r io.Reader, opts UploadOptions) error {
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(p.bucket),
+		Key:    aws.String(key),
+		Body:   r,
+	}
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+	if opts.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(opts.ContentEncoding)
+	}
+	if len(opts.Metadata) > 0 {
+		input.Metadata = aws.StringMap(opts.Metadata)
+	}
+	if p.sseCustomerKey != "" {
+		input.SSECustomerAlgorithm = aws.String(sseCustomerAlgorithm)
+		input.SSECustomerKey = aws.String(p.sseCustomerKey)
+	}
+
+	var setPartSize []func(*s3manager.Uploader)
+	if opts.PartSize > 0 {
+		setPartSize = append(setPartSize, func(u *s3manager.Uploader) {
+			u.PartSize = opts.PartSize
+		})
+	}
+
+	// Parts of large objects are uploaded concurrently
+	if _, err := p.uploader.UploadWithContext(ctx, input, setPartSize...); err != nil {
+		return fmt.Errorf("failed to upload object %s: %w", key, classifyError(err))
+	}
+
+	return nil
+}
+
+// sseCustomerAlgorithm is the only algorithm S3 supports for SSE-C
+const sseCustomerAlgorithm = "AES256"
+
+// objectError explains errors S3 reports for object reads with codes that are hard to act on
+func (p *S3Provider) objectError(err error) error {
+	classified := classifyError(err)
+
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeInvalidObjectState {
+		return fmt.Errorf("object is archived and must be restored first (see 'download-bucket restore'): %w", classified)
+	}
+
+	reqErr, ok := err.(awserr.RequestFailure)
+	if !ok {
+		return classified
+	}
+
+	// SSE-C objects fetched without a key fail with 400 InvalidRequest, and with the wrong key with 403.
+	// Sending a key for an object that is not SSE-C encrypted also fails with 400 InvalidRequest.
+	// Other 400s, such as AuthorizationHeaderMalformed for a wrong region, are passed through.
+	switch {
+	case p.sseCustomerKey == "" && sseCRejected(reqErr):
+		return fmt.Errorf("object may be encrypted with a customer-provided key (SSE-C); configure one with --sse-c-key-file or --sse-c-key-env: %w", classified)
+	case p.sseCustomerKey != "" && reqErr.StatusCode() == http.StatusForbidden:
+		return fmt.Errorf("access denied; check that the SSE-C key is the one the object was encrypted with: %w", classified)
+	case p.sseCustomerKey != "" && sseCRejected(reqErr):
+		return fmt.Errorf("SSE-C key rejected; the object may not be encrypted with a customer-provided key: %w", classified)
+	}
+
+	return classified
+}
+
+// sseCRejected reports whether a request failed the way S3 rejects SSE-C parameters that do not
+// fit the object: with InvalidRequest, or for HEAD requests, which have no body, with a bare 400
+func sseCRejected(reqErr awserr.RequestFailure) bool {
+	if reqErr.StatusCode() != http.StatusBadRequest {
+		return false
+	}
+	return reqErr.Code() == "InvalidRequest" || reqErr.Code() == "BadRequest"
+}
+
+// classifyError tags an S3 error with the kind of failure it is, judging by its
+// error code and HTTP status. Errors of no known kind are returned as they are.
+func classifyError(err error) error {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return err
+	}
+
+	var kind error
+	switch aerr.Code() {
+	case s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchBucket, "NoSuchVersion", "NotFound":
+		kind = ErrNotFound
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken", "NoCredentialProviders":
+		kind = ErrAccessDenied
+	case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded", "TooManyRequests", "ServiceUnavailable":
+		kind = ErrThrottled
+	case s3.ErrCodeInvalidObjectState:
+		kind = ErrInvalidState
+	case request.ErrCodeRequestError, request.ErrCodeResponseTimeout, request.ErrCodeRead, "RequestTimeout":
+		kind = ErrNetwork
+	case request.CanceledErrorCode:
+		kind = context.Canceled
+	}
+
+	// HEAD requests have no body, so only their status tells what went wrong
+	if reqErr, ok := err.(awserr.RequestFailure); ok && kind == nil {
+		switch reqErr.StatusCode() {
+		case http.StatusNotFound:
+			kind = ErrNotFound
+		case http.StatusForbidden, http.StatusUnauthorized:
+			kind = ErrAccessDenied
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			kind = ErrThrottled
+		}
+	}
+
+	if kind == nil {
+		return err
+	}
+	return &Error{Kind: kind, Err: err}
 }
 
 // Close cleans up any resources used by the provider
@@ -0,0 +1,238 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestProvider returns an S3 provider for the bucket "bucket" that sends every request to handler
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *S3Provider {
+	t.Helper()
+
+	// SSE-C keys are only sent over HTTPS
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := NewS3Provider(ProviderOptions{
+		Type:      ProviderTypeS3,
+		Region:    "us-east-1",
+		Endpoint:  server.URL,
+		AccessKey: "key",
+		SecretKey: "secret",
+		Bucket:    "bucket",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.client.Config.HTTPClient = server.Client()
+	p.client.Config.MaxRetries = new(int)
+	return p
+}
+
+const versionsListing = `<?xml version="1.0" encoding="UTF-8"?>
+<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>bucket</Name>
+  <Prefix>data/</Prefix>
+  <IsTruncated>false</IsTruncated>
+  <Version>
+    <Key>data/a</Key>
+    <VersionId>v2</VersionId>
+    <IsLatest>true</IsLatest>
+    <LastModified>2026-09-10T00:00:00.000Z</LastModified>
+    <ETag>"new"</ETag>
+    <Size>3</Size>
+  </Version>
+  <Version>
+    <Key>data/a</Key>
+    <VersionId>null</VersionId>
+    <IsLatest>false</IsLatest>
+    <LastModified>2026-01-01T00:00:00.000Z</LastModified>
+    <ETag>"old"</ETag>
+    <Size>3</Size>
+  </Version>
+</ListVersionsResult>`
+
+func TestNullVersionIsFetchedByID(t *testing.T) {
+	var requestedVersion string
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bucket" {
+			w.Write([]byte(versionsListing))
+			return
+		}
+
+		requestedVersion = r.URL.Query().Get("versionId")
+		if requestedVersion == "null" {
+			w.Write([]byte("old"))
+		} else {
+			w.Write([]byte("new"))
+		}
+	})
+
+	ctx := context.Background()
+	versions, err := p.ListObjectVersions(ctx, "data/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects := VersionsAsOf(versions, day(1).AddDate(0, -3, 0))
+	if len(objects) != 1 || objects[0].VersionID != "null" {
+		t.Fatalf("VersionsAsOf() = %+v, want the null version of data/a", objects)
+	}
+
+	body, err := p.DownloadObjectVersion(ctx, objects[0].Key, objects[0].VersionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	content, _ := io.ReadAll(body)
+
+	if requestedVersion != "null" || string(content) != "old" {
+		t.Errorf("fetched version %q with content %q, want version null with content old", requestedVersion, content)
+	}
+}
+
+func TestObjectErrorSSECHint(t *testing.T) {
+	const hint = "customer-provided key"
+
+	tests := []struct {
+		name     string
+		head     bool
+		status   int
+		body     string
+		withKey  bool
+		wantHint bool
+	}{
+		{name: "GET without key", status: 400, body: errorBody("InvalidRequest"), wantHint: true},
+		{name: "HEAD without key", head: true, status: 400, wantHint: true},
+		{name: "GET with key on a plain object", status: 400, body: errorBody("InvalidRequest"), withKey: true, wantHint: true},
+		{name: "wrong region", status: 400, body: errorBody("AuthorizationHeaderMalformed")},
+		{name: "invalid argument", status: 400, body: errorBody("InvalidArgument")},
+		{name: "wrong key", status: 403, body: errorBody("AccessDenied"), withKey: true, wantHint: true},
+		{name: "access denied without key", status: 403, body: errorBody("AccessDenied")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			if tt.withKey {
+				p.sseCustomerKey = strings.Repeat("k", 32)
+			}
+
+			var err error
+			if tt.head {
+				_, err = p.GetObjectInfo(context.Background(), "data/a")
+			} else {
+				_, err = p.DownloadObject(context.Background(), "data/a")
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			mentionsKey := strings.Contains(err.Error(), hint) || strings.Contains(err.Error(), "SSE-C key")
+			if mentionsKey != tt.wantHint {
+				t.Errorf("error %q: SSE-C hint = %v, want %v", err, mentionsKey, tt.wantHint)
+			}
+		})
+	}
+}
+
+func errorBody(code string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><Error><Code>` + code + `</Code><Message>failed</Message></Error>`
+}
+
+func TestDownloadReturnsObjectInfo(t *testing.T) {
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected %s request", r.Method)
+		}
+		w.Header().Set("Content-Encoding", "zstd")
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("x-amz-meta-owner", "data-team")
+		w.Write([]byte("content"))
+	})
+
+	reader, err := p.DownloadObject(context.Background(), "data/a.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	body, ok := reader.(*ObjectBody)
+	if !ok {
+		t.Fatalf("DownloadObject returned %T, want *ObjectBody", reader)
+	}
+	info := body.Info
+	if info.Key != "data/a.csv" || info.ContentEncoding != "zstd" || info.ContentType != "text/csv" || info.ETag != `"abc"` || info.Size != 7 {
+		t.Errorf("Info = %+v", info)
+	}
+	if info.Metadata["Owner"] != "data-team" {
+		t.Errorf("Metadata = %v", info.Metadata)
+	}
+	if content, _ := io.ReadAll(body); string(content) != "content" {
+		t.Errorf("content = %q", content)
+	}
+}
+
+func TestObjectEncryption(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+		md5ETag bool
+	}{
+		{name: "unencrypted", md5ETag: true},
+		{name: "SSE-S3", headers: map[string]string{"x-amz-server-side-encryption": "AES256"}, want: "AES256", md5ETag: true},
+		{name: "SSE-KMS", headers: map[string]string{"x-amz-server-side-encryption": "aws:kms"}, want: "aws:kms"},
+		{name: "DSSE-KMS", headers: map[string]string{"x-amz-server-side-encryption": "aws:kms:dsse"}, want: "aws:kms:dsse"},
+		{name: "SSE-C", headers: map[string]string{"x-amz-server-side-encryption-customer-algorithm": "AES256"}, want: EncryptionSSEC},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+			})
+
+			info, err := p.GetObjectInfo(context.Background(), "data/a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Encryption != tt.want || info.ETagIsMD5() != tt.md5ETag {
+				t.Errorf("Encryption = %q, ETagIsMD5() = %v; want %q, %v", info.Encryption, info.ETagIsMD5(), tt.want, tt.md5ETag)
+			}
+		})
+	}
+}
+
+func TestParseRestoreHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		status RestoreStatus
+		expiry time.Time
+	}{
+		{"", RestoreNone, time.Time{}},
+		{`ongoing-request="true"`, RestoreInProgress, time.Time{}},
+		{`ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`, RestoreCompleted,
+			time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)},
+		{`ongoing-request="false"`, RestoreCompleted, time.Time{}},
+		{`ongoing-request="false", expiry-date="tomorrow"`, RestoreCompleted, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		status, expiry := parseRestoreHeader(tt.header)
+		if status != tt.status || !expiry.Equal(tt.expiry) {
+			t.Errorf("parseRestoreHeader(%q) = %q, %v; want %q, %v", tt.header, status, expiry, tt.status, tt.expiry)
+		}
+	}
+}
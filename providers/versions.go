@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"sort"
+	"time"
+)
+
+// VersionsAsOf reconstructs the objects that existed at the given time from a version listing.
+// For each key the newest version written at or before t is chosen; keys whose newest
+// version at that time is a delete marker, or that did not exist yet, are left out.
+func VersionsAsOf(versions []Object, t time.Time) []Object {
+	current := make(map[string]Object)
+	for _, v := range versions {
+		if v.LastModified.After(t) {
+			continue
+		}
+		if prev, exists := current[v.Key]; exists && !supersedes(v, prev) {
+			continue
+		}
+		current[v.Key] = v
+	}
+
+	objects := make([]Object, 0, len(current))
+	for _, v := range current {
+		if v.DeleteMarker {
+			continue
+		}
+		objects = append(objects, v)
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	return objects
+}
+
+// supersedes reports whether version v was written after prev. LastModified has one second
+// resolution and listings keep versions and delete markers apart, so for versions of the
+// same second the latest one wins, and otherwise a delete marker wins over a version:
+// the object is not brought back unless it is known to have been written again.
+func supersedes(v, prev Object) bool {
+	if !v.LastModified.Equal(prev.LastModified) {
+		return v.LastModified.After(prev.LastModified)
+	}
+	if v.IsLatest != prev.IsLatest {
+		return v.IsLatest
+	}
+	return v.DeleteMarker && !prev.DeleteMarker
+}
+
+// LatestVersions returns the current version of every object in a version listing,
+// leaving out keys whose latest version is a delete marker
+func LatestVersions(versions []Object) []Object {
+	var objects []Object
+	for _, v := range versions {
+		if v.IsLatest && !v.DeleteMarker {
+			objects = append(objects, v)
+		}
+	}
+	return objects
+}
@@ -0,0 +1,119 @@
+package providers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(d int) time.Time {
+	return time.Date(2026, 9, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestVersionsAsOf(t *testing.T) {
+	versions := []Object{
+		// Written before versioning was enabled, then overwritten twice
+		{Key: "data/a", VersionID: "v3", LastModified: day(20), IsLatest: true},
+		{Key: "data/a", VersionID: "v2", LastModified: day(10)},
+		{Key: "data/a", VersionID: "null", LastModified: day(1)},
+		// Deleted on the 15th
+		{Key: "data/b", VersionID: "m1", LastModified: day(15), DeleteMarker: true, IsLatest: true},
+		{Key: "data/b", VersionID: "v1", LastModified: day(2)},
+		// Created after the 12th
+		{Key: "data/c", VersionID: "v1", LastModified: day(12), IsLatest: true},
+	}
+
+	tests := []struct {
+		at   time.Time
+		want map[string]string
+	}{
+		{day(5), map[string]string{"data/a": "null", "data/b": "v1"}},
+		{day(10), map[string]string{"data/a": "v2", "data/b": "v1"}},
+		{day(16), map[string]string{"data/a": "v2", "data/c": "v1"}},
+		{day(30), map[string]string{"data/a": "v3", "data/c": "v1"}},
+		{day(0), map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		got := make(map[string]string)
+		for _, obj := range VersionsAsOf(versions, tt.at) {
+			got[obj.Key] = obj.VersionID
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("VersionsAsOf(%s) = %v, want %v", tt.at.Format("Jan 2"), got, tt.want)
+		}
+	}
+}
+
+func TestVersionsAsOfSameSecond(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []Object
+		want     map[string]string
+	}{
+		{"deleted in the second it was written", []Object{
+			{Key: "a", VersionID: "v2", LastModified: day(3)},
+			{Key: "a", VersionID: "v1", LastModified: day(1)},
+			{Key: "a", VersionID: "m1", LastModified: day(3), DeleteMarker: true},
+			{Key: "b", VersionID: "m1", LastModified: day(3), DeleteMarker: true},
+			{Key: "b", VersionID: "v1", LastModified: day(3)},
+		}, map[string]string{}},
+		{"written again in the second it was deleted", []Object{
+			{Key: "a", VersionID: "v2", LastModified: day(3), IsLatest: true},
+			{Key: "a", VersionID: "m1", LastModified: day(3), DeleteMarker: true},
+		}, map[string]string{"a": "v2"}},
+		{"deleted last", []Object{
+			{Key: "a", VersionID: "v2", LastModified: day(3)},
+			{Key: "a", VersionID: "m1", LastModified: day(3), DeleteMarker: true, IsLatest: true},
+		}, map[string]string{}},
+		{"two versions", []Object{
+			{Key: "a", VersionID: "v1", LastModified: day(3)},
+			{Key: "a", VersionID: "v2", LastModified: day(3), IsLatest: true},
+		}, map[string]string{"a": "v2"}},
+	}
+
+	for _, tt := range tests {
+		got := make(map[string]string)
+		for _, obj := range VersionsAsOf(tt.versions, day(5)) {
+			got[obj.Key] = obj.VersionID
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: VersionsAsOf = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVersionsAsOfSortsByKey(t *testing.T) {
+	versions := []Object{
+		{Key: "c", LastModified: day(1)},
+		{Key: "a", LastModified: day(1)},
+		{Key: "b", LastModified: day(1)},
+	}
+
+	var keys []string
+	for _, obj := range VersionsAsOf(versions, day(2)) {
+		keys = append(keys, obj.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c"}) {
+		t.Errorf("VersionsAsOf keys = %v, want sorted", keys)
+	}
+}
+
+func TestLatestVersions(t *testing.T) {
+	versions := []Object{
+		{Key: "data/a", VersionID: "v2", IsLatest: true},
+		{Key: "data/a", VersionID: "null"},
+		{Key: "data/b", VersionID: "m1", IsLatest: true, DeleteMarker: true},
+		{Key: "data/b", VersionID: "v1"},
+		{Key: "data/c", VersionID: "null", IsLatest: true},
+	}
+
+	got := make(map[string]string)
+	for _, obj := range LatestVersions(versions) {
+		got[obj.Key] = obj.VersionID
+	}
+	want := map[string]string{"data/a": "v2", "data/c": "null"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LatestVersions() = %v, want %v", got, want)
+	}
+}
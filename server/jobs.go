@@ -0,0 +1,479 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/providers"
+	"download-file-from-bucket/tracing"
+)
+
+// JobStatus is the state of a job
+type JobStatus string
+
+const (
+	// StatusRunning means the job is listing or downloading objects
+	StatusRunning JobStatus = "running"
+	// StatusCompleted means every object was downloaded
+	StatusCompleted JobStatus = "completed"
+	// StatusFailed means the job stopped with an error or some objects failed
+	StatusFailed JobStatus = "failed"
+	// StatusCancelled means the job was cancelled before it finished
+	StatusCancelled JobStatus = "cancelled"
+)
+
+// JobRequest describes a job to run: the sources to download and where to put them
+type JobRequest struct {
+	Sources []string `json:"sources"`
+	// Destination is a local directory, an archive file, or a bucket URL to copy into
+	Destination  string        `json:"destination"`
+	Concurrency  int           `json:"concurrency,omitempty"`
+	SkipArchived bool          `json:"skip_archived,omitempty"`
+	Filter       FilterRequest `json:"filter"`
+	// FailFast, MaxErrors and MaxErrorRate (a fraction) abort the job early; see downloader.FailurePolicy
+	FailFast     bool    `json:"fail_fast,omitempty"`
+	MaxErrors    int     `json:"max_errors,omitempty"`
+	MaxErrorRate float64 `json:"max_error_rate,omitempty"`
+}
+
+// FilterRequest selects objects by their properties; see downloader.Filter
+type FilterRequest struct {
+	NewerThan *time.Time        `json:"newer_than,omitempty"`
+	OlderThan *time.Time        `json:"older_than,omitempty"`
+	MinSize   int64             `json:"min_size,omitempty"`
+	MaxSize   int64             `json:"max_size,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// downloaderFilter converts the request into a downloader filter
+func (f FilterRequest) downloaderFilter() downloader.Filter {
+	filter := downloader.Filter{
+		MinSize:  f.MinSize,
+		MaxSize:  f.MaxSize,
+		Metadata: f.Metadata,
+		Tags:     f.Tags,
+	}
+	if f.NewerThan != nil {
+		filter.NewerThan = *f.NewerThan
+	}
+	if f.OlderThan != nil {
+		filter.OlderThan = *f.OlderThan
+	}
+	return filter
+}
+
+// Progress counts the objects a job has handled so far
+type Progress struct {
+	CompletedFiles int   `json:"completed_files"`
+	FailedFiles    int   `json:"failed_files"`
+	Bytes          int64 `json:"bytes"`
+}
+
+// JobResult summarises a finished job
+type JobResult struct {
+	TotalFiles      int      `json:"total_files"`
+	SuccessfulFiles int      `json:"successful_files"`
+	FailedFiles     int      `json:"failed_files"`
+	SkippedFiles    int      `json:"skipped_files"`
+	RemainingFiles  int      `json:"remaining_files"`
+	TotalBytes      int64    `json:"total_bytes"`
+	Duration        string   `json:"duration"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+// JobInfo is a snapshot of a job, as returned by the API
+type JobInfo struct {
+	ID         string     `json:"id"`
+	Status     JobStatus  `json:"status"`
+	Request    JobRequest `json:"request"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Progress   Progress   `json:"progress"`
+	Result     *JobResult `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Event is sent to the subscribers of a job
+type Event struct {
+	// Type is "status" when a stream starts, "progress" for each object and "done" when the job finishes
+	Type string `json:"type"`
+	// Key, Bytes and Error describe the object of a progress event
+	Key   string  `json:"key,omitempty"`
+	Bytes int64   `json:"bytes,omitempty"`
+	Error string  `json:"error,omitempty"`
+	Job   JobInfo `json:"job"`
+}
+
+// job is a running or finished job
+type job struct {
+	mu          sync.Mutex
+	info        JobInfo
+	cancel      context.CancelFunc
+	done        bool
+	subscribers map[chan Event]struct{}
+}
+
+// snapshot returns a copy of the job's state
+func (j *job) snapshot() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
+
+// publish sends an event to every subscriber, dropping it for subscribers that are not keeping up.
+// The caller must hold j.mu.
+func (j *job) publish(event Event) {
+	event.Job = j.info
+	for ch := range j.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// subscribe returns a channel of the job's events, closed when the job finishes, and a function to stop
+func (j *job) subscribe() (<-chan Event, func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	ch := make(chan Event, 64)
+	if j.done {
+		close(ch)
+		return ch, func() {}
+	}
+
+	j.subscribers[ch] = struct{}{}
+	return ch, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.subscribers[ch]; ok {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// SourceOpener resolves a source URL into a download source with its provider
+type SourceOpener func(url string) (downloader.Source, error)
+
+// SinkOpener opens a job destination; the returned function finishes and closes it
+type SinkOpener func(ctx context.Context, destination string) (downloader.Sink, func() error, error)
+
+// Defaults for how long and how many finished jobs are kept
+const (
+	DefaultRetention   = time.Hour
+	DefaultMaxFinished = 1000
+)
+
+// ManagerOptions configures a manager
+type ManagerOptions struct {
+	// MaxConcurrency is the number of downloads allowed at once across all jobs
+	MaxConcurrency int
+	// Validate, if set, checks the sources and destination of a request before its job starts
+	Validate func(JobRequest) error
+	// Retention is how long finished jobs are kept; defaults to DefaultRetention
+	Retention time.Duration
+	// MaxFinished caps the number of finished jobs kept, dropping the oldest first; defaults to DefaultMaxFinished
+	MaxFinished int
+}
+
+// Manager runs jobs concurrently, sharing one download budget between them
+type Manager struct {
+	openSource  SourceOpener
+	openSink    SinkOpener
+	validate    func(JobRequest) error
+	budget      *downloader.Budget
+	retention   time.Duration
+	maxFinished int
+
+	ctx    context.Context
+	mu     sync.Mutex
+	jobs   map[string]*job
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// NewManager creates a manager that opens the sources and destinations of jobs with the given functions
+func NewManager(openSource SourceOpener, openSink SinkOpener, opts ManagerOptions) *Manager {
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if opts.MaxFinished <= 0 {
+		opts.MaxFinished = DefaultMaxFinished
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		openSource:  openSource,
+		openSink:    openSink,
+		validate:    opts.Validate,
+		budget:      downloader.NewBudget(opts.MaxConcurrency),
+		retention:   opts.Retention,
+		maxFinished: opts.MaxFinished,
+		ctx:         ctx,
+		cancel:      cancel,
+		jobs:        make(map[string]*job),
+	}
+}
+
+// Submit validates a request and starts a job for it
+func (m *Manager) Submit(req JobRequest) (JobInfo, error) {
+	if len(req.Sources) == 0 {
+		return JobInfo{}, fmt.Errorf("at least one source is required")
+	}
+	if req.Destination == "" || req.Destination == "-" {
+		return JobInfo{}, fmt.Errorf("a destination is required")
+	}
+	if m.validate != nil {
+		if err := m.validate(req); err != nil {
+			return JobInfo{}, err
+		}
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return JobInfo{}, err
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	j := &job{
+		info: JobInfo{
+			ID:        id,
+			Status:    StatusRunning,
+			Request:   req,
+			CreatedAt: time.Now().UTC(),
+		},
+		cancel:      cancel,
+		subscribers: make(map[chan Event]struct{}),
+	}
+
+	m.mu.Lock()
+	m.evict(time.Now())
+	m.jobs[id] = j
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer cancel()
+		m.run(ctx, j)
+	}()
+
+	return j.snapshot(), nil
+}
+
+// Jobs returns a snapshot of every job, oldest first
+func (m *Manager) Jobs() []JobInfo {
+	m.mu.Lock()
+	m.evict(time.Now())
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	m.mu.Unlock()
+
+	infos := make([]JobInfo, 0, len(jobs))
+	for _, j := range jobs {
+		infos = append(infos, j.snapshot())
+	}
+	sort.Slice(infos, func(a, b int) bool {
+		return infos[a].CreatedAt.Before(infos[b].CreatedAt)
+	})
+	return infos
+}
+
+// Job returns a snapshot of a job
+func (m *Manager) Job(id string) (JobInfo, bool) {
+	j, ok := m.job(id)
+	if !ok {
+		return JobInfo{}, false
+	}
+	return j.snapshot(), true
+}
+
+// Cancel stops a running job; cancelling a finished job does nothing
+func (m *Manager) Cancel(id string) (JobInfo, bool) {
+	j, ok := m.job(id)
+	if !ok {
+		return JobInfo{}, false
+	}
+	j.cancel()
+	return j.snapshot(), true
+}
+
+// Subscribe returns the events of a job until it finishes
+func (m *Manager) Subscribe(id string) (<-chan Event, func(), bool) {
+	j, ok := m.job(id)
+	if !ok {
+		return nil, nil, false
+	}
+	events, unsubscribe := j.subscribe()
+	return events, unsubscribe, true
+}
+
+// Close cancels every job and waits for them to stop
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// evict forgets the jobs that finished more than the retention period ago, and the oldest
+// finished jobs beyond the cap. Running jobs are always kept. The caller must hold m.mu.
+func (m *Manager) evict(now time.Time) {
+	type finishedJob struct {
+		id         string
+		finishedAt time.Time
+	}
+	var finished []finishedJob
+	for id, j := range m.jobs {
+		info := j.snapshot()
+		if info.FinishedAt == nil {
+			continue
+		}
+		if now.Sub(*info.FinishedAt) > m.retention {
+			delete(m.jobs, id)
+			continue
+		}
+		finished = append(finished, finishedJob{id: id, finishedAt: *info.FinishedAt})
+	}
+
+	if excess := len(finished) - m.maxFinished; excess > 0 {
+		sort.Slice(finished, func(a, b int) bool {
+			return finished[a].finishedAt.Before(finished[b].finishedAt)
+		})
+		for _, f := range finished[:excess] {
+			delete(m.jobs, f.id)
+		}
+	}
+}
+
+func (m *Manager) job(id string) (*job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	return j, ok
+}
+
+// run downloads the sources of a job and records the outcome
+func (m *Manager) run(ctx context.Context, j *job) {
+	req := j.snapshot().Request
+
+	ctx, span := tracing.Start(ctx, "job",
+		attribute.String("job.id", j.snapshot().ID),
+		attribute.StringSlice("sources", req.Sources),
+		attribute.String("destination", req.Destination),
+	)
+	result, err := m.download(ctx, j, req)
+	tracing.End(span, err)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	finished := time.Now().UTC()
+	j.info.FinishedAt = &finished
+	switch {
+	case ctx.Err() != nil:
+		j.info.Status = StatusCancelled
+	case err != nil:
+		j.info.Status = StatusFailed
+		j.info.Error = err.Error()
+	case result.Err() != nil:
+		j.info.Status = StatusFailed
+		j.info.Error = result.Err().Error()
+	default:
+		j.info.Status = StatusCompleted
+	}
+
+	if result != nil {
+		j.info.Result = &JobResult{
+			TotalFiles:      result.TotalFiles,
+			SuccessfulFiles: result.SuccessfulFiles,
+			FailedFiles:     result.FailedFiles,
+			SkippedFiles:    result.SkippedFiles,
+			RemainingFiles:  result.RemainingFiles,
+			TotalBytes:      result.TotalBytes,
+			Duration:        result.Duration.String(),
+		}
+		for _, err := range result.Errors {
+			j.info.Result.Errors = append(j.info.Result.Errors, err.Error())
+		}
+	}
+
+	j.publish(Event{Type: "done"})
+	j.done = true
+	for ch := range j.subscribers {
+		close(ch)
+	}
+	j.subscribers = nil
+}
+
+// download opens the sources and destination of a job and runs it
+func (m *Manager) download(ctx context.Context, j *job, req JobRequest) (*downloader.DownloadResult, error) {
+	var sources []downloader.Source
+	for _, url := range req.Sources {
+		source, err := m.openSource(url)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", url, err)
+		}
+		defer source.Provider.Close()
+		sources = append(sources, source)
+	}
+
+	sink, closeSink, err := m.openSink(ctx, req.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	dl := downloader.NewDownloader(sources[0].Provider, downloader.Options{
+		Concurrency:  req.Concurrency,
+		SkipArchived: req.SkipArchived,
+		Filter:       req.Filter.downloaderFilter(),
+		Failures: downloader.FailurePolicy{
+			FailFast:     req.FailFast,
+			MaxErrors:    req.MaxErrors,
+			MaxErrorRate: req.MaxErrorRate,
+		},
+		Budget: m.budget,
+		Logger: slog.Default().With("job", j.snapshot().ID),
+	})
+
+	result, err := dl.DownloadSources(ctx, sources, sink, func(progress providers.DownloadProgress) {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+
+		event := Event{Type: "progress", Key: progress.Key, Bytes: progress.BytesDownloaded}
+		if progress.Error != nil {
+			j.info.Progress.FailedFiles++
+			event.Error = progress.Error.Error()
+		} else {
+			j.info.Progress.CompletedFiles++
+			j.info.Progress.Bytes += progress.BytesDownloaded
+		}
+		j.publish(event)
+	})
+	if closeErr := closeSink(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+
+	return result, err
+}
+
+// newJobID creates a random job identifier
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
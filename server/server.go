@@ -0,0 +1,206 @@
+// Package server exposes download jobs over an HTTP API
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"download-file-from-bucket/metrics"
+)
+
+// Server handles the HTTP API:
+//
+//	GET    /healthz               liveness check
+//	GET    /metrics               Prometheus metrics
+//	GET    /jobs                  list jobs
+//	POST   /jobs                  submit a job (JobRequest body)
+//	GET    /jobs/{id}             show a job
+//	DELETE /jobs/{id}             cancel a job
+//	POST   /jobs/{id}/cancel      cancel a job
+//	GET    /jobs/{id}/events      stream job events as server-sent events
+//
+// Every endpoint but /healthz requires an "Authorization: Bearer <token>" header.
+type Server struct {
+	manager *Manager
+	token   string
+}
+
+// NewServer creates an HTTP handler for the jobs of a manager, accepting requests that carry token
+func NewServer(manager *Manager, token string) *Server {
+	return &Server{manager: manager, token: token}
+}
+
+// ServeHTTP routes a request to its handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+
+	if path != "healthz" && !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return
+	}
+
+	switch {
+	case path == "healthz":
+		s.allow(w, r, http.MethodGet, func() {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		})
+	case path == "metrics":
+		s.allow(w, r, http.MethodGet, func() { metrics.Handler().ServeHTTP(w, r) })
+	case path == "jobs":
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, s.manager.Jobs())
+		case http.MethodPost:
+			s.submit(w, r)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+	case len(parts) == 2 && parts[0] == "jobs":
+		switch r.Method {
+		case http.MethodGet:
+			s.show(w, parts[1])
+		case http.MethodDelete:
+			s.cancel(w, parts[1])
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+		}
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "cancel":
+		s.allow(w, r, http.MethodPost, func() { s.cancel(w, parts[1]) })
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "events":
+		s.allow(w, r, http.MethodGet, func() { s.events(w, r, parts[1]) })
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// allow runs handle if the request uses the given method
+func (s *Server) allow(w http.ResponseWriter, r *http.Request, method string, handle func()) {
+	if r.Method != method {
+		methodNotAllowed(w, method)
+		return
+	}
+	handle()
+}
+
+// authorized reports whether the request carries the server's bearer token
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || s.token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	// Browsers can send text/plain and form bodies to any origin without a preflight
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "job requests must be sent as application/json")
+		return
+	}
+
+	var req JobRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid job request: %v", err))
+		return
+	}
+
+	info, err := s.manager.Submit(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/jobs/"+info.ID)
+	writeJSON(w, http.StatusAccepted, info)
+}
+
+func (s *Server) show(w http.ResponseWriter, id string) {
+	info, ok := s.manager.Job(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) cancel(w http.ResponseWriter, id string) {
+	info, ok := s.manager.Cancel(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, info)
+}
+
+// events streams a job's events until it finishes or the client goes away
+func (s *Server) events(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	events, unsubscribe, ok := s.manager.Subscribe(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Start with the current state so late subscribers see finished jobs too
+	if info, ok := s.manager.Job(id); ok {
+		writeEvent(w, Event{Type: "status", Job: info})
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			writeEvent(w, event)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one server-sent event
+func writeEvent(w http.ResponseWriter, event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
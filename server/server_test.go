@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"download-file-from-bucket/downloader"
+)
+
+// failingOpener fails every job as soon as it starts
+func failingOpener(url string) (downloader.Source, error) {
+	return downloader.Source{}, errors.New("no provider")
+}
+
+func noSink(ctx context.Context, destination string) (downloader.Sink, func() error, error) {
+	return nil, nil, errors.New("no sink")
+}
+
+func newTestServer(t *testing.T, opts ManagerOptions) (*Server, *Manager) {
+	t.Helper()
+	if opts.MaxConcurrency == 0 {
+		opts.MaxConcurrency = 1
+	}
+	manager := NewManager(failingOpener, noSink, opts)
+	t.Cleanup(manager.Close)
+	return NewServer(manager, "secret"), manager
+}
+
+func request(s *Server, method, path, token, contentType, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+const jobBody = `{"sources":["s3://bucket/data/"],"destination":"data"}`
+
+func TestServerRequiresToken(t *testing.T) {
+	s, _ := newTestServer(t, ManagerOptions{})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"health check without token", http.MethodGet, "/healthz", "", http.StatusOK},
+		{"jobs without token", http.MethodGet, "/jobs", "", http.StatusUnauthorized},
+		{"jobs with wrong token", http.MethodGet, "/jobs", "wrong", http.StatusUnauthorized},
+		{"jobs with token", http.MethodGet, "/jobs", "secret", http.StatusOK},
+		{"metrics without token", http.MethodGet, "/metrics", "", http.StatusUnauthorized},
+		{"submit without token", http.MethodPost, "/jobs", "", http.StatusUnauthorized},
+		{"cancel without token", http.MethodDelete, "/jobs/x", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		w := request(s, tt.method, tt.path, tt.token, "application/json", jobBody)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestServerAcceptsOnlyJSONJobs(t *testing.T) {
+	s, _ := newTestServer(t, ManagerOptions{})
+
+	tests := []struct {
+		contentType string
+		want        int
+	}{
+		{"", http.StatusUnsupportedMediaType},
+		{"text/plain", http.StatusUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"application/json", http.StatusAccepted},
+		{"application/json; charset=utf-8", http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		w := request(s, http.MethodPost, "/jobs", "secret", tt.contentType, jobBody)
+		if w.Code != tt.want {
+			t.Errorf("Content-Type %q: status %d, want %d", tt.contentType, w.Code, tt.want)
+		}
+	}
+}
+
+func TestSubmitRejectsInvalidRequests(t *testing.T) {
+	s, manager := newTestServer(t, ManagerOptions{
+		Validate: func(req JobRequest) error {
+			if req.Destination == "../outside" {
+				return errors.New("destination is outside the destination root")
+			}
+			return nil
+		},
+	})
+
+	for _, body := range []string{
+		`{"sources":[],"destination":"data"}`,
+		`{"sources":["s3://bucket/data/"],"destination":"-"}`,
+		`{"sources":["s3://bucket/data/"],"destination":"../outside"}`,
+		`{"sources":["s3://bucket/data/"],"destination":"data","unknown":1}`,
+	} {
+		w := request(s, http.MethodPost, "/jobs", "secret", "application/json", body)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+	if jobs := manager.Jobs(); len(jobs) != 0 {
+		t.Errorf("rejected requests started %d jobs", len(jobs))
+	}
+}
+
+// waitFinished waits until every job of the manager has finished
+func waitFinished(t *testing.T, manager *Manager) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		running := 0
+		for _, info := range manager.Jobs() {
+			if info.FinishedAt == nil {
+				running++
+			}
+		}
+		if running == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("jobs did not finish")
+}
+
+func TestFinishedJobsAreEvicted(t *testing.T) {
+	manager := NewManager(failingOpener, noSink, ManagerOptions{MaxConcurrency: 1, Retention: time.Hour, MaxFinished: 3})
+	defer manager.Close()
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		info, err := manager.Submit(JobRequest{Sources: []string{"s3://bucket/"}, Destination: "data"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, info.ID)
+		waitFinished(t, manager)
+	}
+
+	// Only the three most recently finished jobs are kept
+	jobs := manager.Jobs()
+	if len(jobs) != 3 {
+		t.Fatalf("kept %d jobs, want 3", len(jobs))
+	}
+	for _, id := range ids[:2] {
+		if _, ok := manager.Job(id); ok {
+			t.Errorf("job %s should have been evicted", id)
+		}
+	}
+
+	// Once the retention period has passed, none are
+	manager.mu.Lock()
+	manager.evict(time.Now().Add(2 * time.Hour))
+	manager.mu.Unlock()
+	if jobs := manager.Jobs(); len(jobs) != 0 {
+		t.Errorf("kept %d jobs after the retention period, want 0", len(jobs))
+	}
+}
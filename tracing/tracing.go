@@ -0,0 +1,112 @@
+// Package tracing sets up OpenTelemetry tracing and starts spans for the tool's phases
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifies the tool in traces
+const ServiceName = "download-bucket"
+
+// Exporter names where spans are sent
+type Exporter string
+
+const (
+	// ExporterNone disables tracing
+	ExporterNone Exporter = "none"
+	// ExporterOTLP sends spans to an OTLP/HTTP collector
+	ExporterOTLP Exporter = "otlp"
+	// ExporterStdout prints spans as JSON to stderr, keeping stdout free for tar streams
+	ExporterStdout Exporter = "stdout"
+)
+
+// ParseExporter validates an exporter name; an empty name disables tracing
+func ParseExporter(name string) (Exporter, error) {
+	switch Exporter(strings.ToLower(name)) {
+	case "", ExporterNone:
+		return ExporterNone, nil
+	case ExporterOTLP:
+		return ExporterOTLP, nil
+	case ExporterStdout:
+		return ExporterStdout, nil
+	default:
+		return "", fmt.Errorf("invalid trace exporter %q: must be none, otlp or stdout", name)
+	}
+}
+
+// Setup installs a global tracer provider sending spans to exporter.
+// endpoint is the OTLP collector URL; if empty, the standard OTEL_EXPORTER_OTLP_* variables apply.
+// The returned function flushes pending spans and must be called before the process exits.
+func Setup(ctx context.Context, exporter Exporter, endpoint string) (func(context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		spanExporter, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span under any span in ctx. Until Setup is called spans are not recorded.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(ServiceName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Record adds a span for something that started at start and has just finished
+func Record(ctx context.Context, name string, start time.Time, err error, attrs ...attribute.KeyValue) {
+	_, span := otel.Tracer(ServiceName).Start(ctx, name, trace.WithTimestamp(start), trace.WithAttributes(attrs...))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// End marks span as failed if err is set, then ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
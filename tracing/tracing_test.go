@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestParseExporter(t *testing.T) {
+	tests := []struct {
+		name string
+		want Exporter
+		ok   bool
+	}{
+		{"", ExporterNone, true},
+		{"none", ExporterNone, true},
+		{"OTLP", ExporterOTLP, true},
+		{"stdout", ExporterStdout, true},
+		{"jaeger", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseExporter(tt.name)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseExporter(%q) = %q, %v; want %q, ok %v", tt.name, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSetupNoneIsANoop(t *testing.T) {
+	shutdown, err := Setup(context.Background(), ExporterNone, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	ctx, parent := Start(context.Background(), "download", attribute.String("prefix", "data/"))
+	start := time.Now().Add(-time.Second)
+	Record(ctx, "get", start, errors.New("access denied"), attribute.String("key", "data/a.txt"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	get, download := spans[0], spans[1]
+
+	if get.Name() != "get" || get.Parent().SpanID() != download.SpanContext().SpanID() {
+		t.Errorf("span %q is not a child of the download span", get.Name())
+	}
+	if !get.StartTime().Equal(start) {
+		t.Errorf("recorded span starts at %v, want %v", get.StartTime(), start)
+	}
+	if get.Status().Code != codes.Error || get.Status().Description != "access denied" {
+		t.Errorf("failed span status = %+v", get.Status())
+	}
+	if download.Status().Code == codes.Error {
+		t.Errorf("successful span marked as failed")
+	}
+	if attrs := download.Attributes(); len(attrs) != 1 || attrs[0] != attribute.String("prefix", "data/") {
+		t.Errorf("download span attributes = %v", attrs)
+	}
+}
@@ -0,0 +1,104 @@
+package uploader
+
+import (
+	"context"
+	"sync"
+)
+
+// Action is what an upload would do with a local file
+type Action string
+
+const (
+	// ActionUpload uploads a file that has no object yet
+	ActionUpload Action = "upload"
+	// ActionOverwrite replaces an object whose size or content differs
+	ActionOverwrite Action = "overwrite"
+	// ActionUnchanged skips a file whose object already matches it
+	ActionUnchanged Action = "unchanged"
+)
+
+// PlannedFile is a local file and what an upload would do with it
+type PlannedFile struct {
+	Path   string
+	Key    string
+	Size   int64
+	Action Action
+}
+
+// Plan describes what UploadFolder would do, without having uploaded anything
+type Plan struct {
+	Files []PlannedFile
+	// Errors holds the files that could not be compared with their objects
+	Errors []error
+
+	UploadFiles    int
+	OverwriteFiles int
+	UnchangedFiles int
+	UploadBytes    int64
+	OverwriteBytes int64
+}
+
+// PlanFolder lists localDir and the objects under prefix and works out which files UploadFolder
+// would upload. Files whose size matches their object are read to compare checksums.
+func (u *Uploader) PlanFolder(ctx context.Context, localDir, prefix string) (*Plan, error) {
+	files, remote, err := u.list(ctx, localDir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	planned := make([]PlannedFile, len(files))
+	errs := make([]error, len(files))
+	indexes := make(chan int, len(files))
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+
+	// Comparing checksums reads every file that might be unchanged, so it runs concurrently
+	var wg sync.WaitGroup
+	for w := 0; w < u.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				f := files[i]
+				planned[i] = PlannedFile{Path: f.path, Key: f.key, Size: f.size, Action: ActionUpload}
+
+				same, err := u.unchanged(ctx, f, remote)
+				switch {
+				case err != nil:
+					errs[i] = err
+				case same:
+					planned[i].Action = ActionUnchanged
+				default:
+					if _, exists := remote[f.key]; exists {
+						planned[i].Action = ActionOverwrite
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	plan := &Plan{}
+	for i, file := range planned {
+		if errs[i] != nil {
+			plan.Errors = append(plan.Errors, errs[i])
+			continue
+		}
+
+		switch file.Action {
+		case ActionUpload:
+			plan.UploadFiles++
+			plan.UploadBytes += file.Size
+		case ActionOverwrite:
+			plan.OverwriteFiles++
+			plan.OverwriteBytes += file.Size
+		case ActionUnchanged:
+			plan.UnchangedFiles++
+		}
+		plan.Files = append(plan.Files, file)
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,251 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"download-file-from-bucket/checksum"
+	"download-file-from-bucket/localfiles"
+	"download-file-from-bucket/providers"
+)
+
+// Uploader handles uploading local files to cloud storage
+type Uploader struct {
+	provider    providers.Provider
+	concurrency int
+	partSize    int64
+	logger      *slog.Logger
+}
+
+// Options for configuring the uploader
+type Options struct {
+	Concurrency int
+	// PartSize is the multipart upload part size; it is also used to compare multipart ETags
+	PartSize int64
+	// Logger receives progress at debug level; defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+// NewUploader creates a new uploader
+func NewUploader(provider providers.Provider, opts Options) *Uploader {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 5 // Default concurrency
+	}
+	if opts.PartSize <= 0 {
+		opts.PartSize = checksum.DefaultPartSize
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	return &Uploader{
+		provider:    provider,
+		concurrency: opts.Concurrency,
+		partSize:    opts.PartSize,
+		logger:      opts.Logger,
+	}
+}
+
+// UploadResult represents the result of an upload operation
+type UploadResult struct {
+	TotalFiles     int
+	UploadedFiles  int
+	UnchangedFiles int
+	FailedFiles    int
+	TotalBytes     int64
+	Duration       time.Duration
+	Errors         []error
+}
+
+// localFile is a file found under the directory being uploaded
+type localFile struct {
+	path string
+	key  string
+	size int64
+}
+
+// UploadFolder uploads every file under localDir to keys under prefix.
+// Files whose size and content already match the remote object are skipped.
+func (u *Uploader) UploadFolder(ctx context.Context, localDir, prefix string) (*UploadResult, error) {
+	startTime := time.Now()
+
+	files, remote, err := u.list(ctx, localDir, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make(chan localFile, len(files))
+	for _, f := range files {
+		jobs <- f
+	}
+	close(jobs)
+
+	var mu sync.Mutex
+	result := &UploadResult{TotalFiles: len(files)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < u.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				uploaded, err := u.uploadFile(ctx, f, remote)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					result.FailedFiles++
+					result.Errors = append(result.Errors, err)
+				case uploaded:
+					result.UploadedFiles++
+					result.TotalBytes += f.size
+				default:
+					result.UnchangedFiles++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	result.Duration = time.Since(startTime)
+	return result, nil
+}
+
+// list finds the files under localDir and indexes the objects already under prefix for change detection
+func (u *Uploader) list(ctx context.Context, localDir, prefix string) ([]localFile, map[string]providers.Object, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	files, err := listLocalFiles(localDir, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objects, err := u.provider.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	remote := make(map[string]providers.Object, len(objects))
+	for _, obj := range objects {
+		remote[obj.Key] = obj
+	}
+
+	u.logger.DebugContext(ctx, "found files to upload", "local", len(files), "remote", len(objects))
+
+	return files, remote, nil
+}
+
+// uploadFile uploads a single file unless the remote copy is already identical
+func (u *Uploader) uploadFile(ctx context.Context, f localFile, remote map[string]providers.Object) (bool, error) {
+	same, err := u.unchanged(ctx, f, remote)
+	if err != nil {
+		return false, err
+	}
+	if same {
+		u.logger.DebugContext(ctx, "unchanged", "key", f.key)
+		return false, nil
+	}
+
+	sum, err := checksum.FileSHA256(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+	defer file.Close()
+
+	opts := providers.UploadOptions{
+		ContentType: mime.TypeByExtension(path.Ext(f.key)),
+		Metadata:    map[string]string{checksum.MetadataKey: sum},
+		PartSize:    u.partSize,
+	}
+	if err := u.provider.UploadObject(ctx, f.key, file, opts); err != nil {
+		return false, err
+	}
+
+	u.logger.DebugContext(ctx, "uploaded", "path", f.path, "key", f.key)
+
+	return true, nil
+}
+
+// unchanged reports whether the remote object for a file already has the same size and content.
+// Listings don't tell how objects are encrypted, so when the ETag does not match, the object is
+// fetched to see whether its ETag is an MD5 at all; if not, as for SSE-C and SSE-KMS objects,
+// the file is compared with the checksum stored in the object's metadata instead.
+func (u *Uploader) unchanged(ctx context.Context, f localFile, remote map[string]providers.Object) (bool, error) {
+	obj, exists := remote[f.key]
+	if !exists || obj.Size != f.size {
+		return false, nil
+	}
+
+	same, err := checksum.FileMatchesETag(f.path, obj.ETag, u.partSize)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", f.path, err)
+	}
+	if same {
+		return true, nil
+	}
+
+	info, err := u.provider.GetObjectInfo(ctx, f.key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", f.key, err)
+	}
+	if info.ETagIsMD5() {
+		return false, nil
+	}
+
+	stored := metadataValue(info.Metadata, checksum.MetadataKey)
+	if stored == "" {
+		return false, nil
+	}
+	sum, err := checksum.FileSHA256(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to check %s: %w", f.path, err)
+	}
+	return sum == stored, nil
+}
+
+// metadataValue looks up a user metadata value; providers differ in how they case the keys
+func metadataValue(metadata map[string]string, key string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// listLocalFiles finds every regular file under dir and the key it will be uploaded to
+func listLocalFiles(dir, prefix string) ([]localFile, error) {
+	// Unlike a download destination, a missing source directory is a mistake
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read local directory: %w", err)
+	}
+
+	found, err := localfiles.List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]localFile, 0, len(found))
+	for _, file := range found {
+		files = append(files, localFile{
+			path: file.Path,
+			key:  prefix + file.Name,
+			size: file.Size,
+		})
+	}
+	return files, nil
+}
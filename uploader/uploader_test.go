@@ -0,0 +1,174 @@
+package uploader
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"download-file-from-bucket/providers"
+)
+
+// bucketProvider keeps uploaded objects in memory. With sseC set it stores them as S3 does
+// with a customer key: the ETag is not the MD5 of the content.
+type bucketProvider struct {
+	providers.Provider
+	sseC bool
+
+	mu      sync.Mutex
+	objects map[string]providers.Object
+	content map[string][]byte
+	uploads []string
+	heads   int
+}
+
+func newBucketProvider(sseC bool) *bucketProvider {
+	return &bucketProvider{sseC: sseC, objects: make(map[string]providers.Object), content: make(map[string][]byte)}
+}
+
+func (p *bucketProvider) ListObjects(ctx context.Context, prefix string) ([]providers.Object, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var objects []providers.Object
+	for key, obj := range p.objects {
+		if strings.HasPrefix(key, prefix) {
+			// Listings carry neither the encryption nor the metadata
+			objects = append(objects, providers.Object{Key: key, Size: obj.Size, ETag: obj.ETag})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (p *bucketProvider) GetObjectInfo(ctx context.Context, key string) (*providers.Object, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.heads++
+	obj, ok := p.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, providers.ErrNotFound)
+	}
+	return &obj, nil
+}
+
+func (p *bucketProvider) UploadObject(ctx context.Context, key string, r io.Reader, opts providers.UploadOptions) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	obj := providers.Object{Key: key, Size: int64(len(content)), Metadata: opts.Metadata}
+	if p.sseC {
+		random := make([]byte, md5.Size)
+		rand.Read(random)
+		obj.ETag = fmt.Sprintf(`"%x"`, random)
+		obj.Encryption = providers.EncryptionSSEC
+	} else {
+		obj.ETag = fmt.Sprintf(`"%x"`, md5.Sum(content))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.objects[key] = obj
+	p.content[key] = content
+	p.uploads = append(p.uploads, key)
+	return nil
+}
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func push(t *testing.T, p *bucketProvider, dir string) *UploadResult {
+	t.Helper()
+	p.uploads = nil
+	result, err := NewUploader(p, Options{Concurrency: 2}).UploadFolder(context.Background(), dir, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FailedFiles != 0 {
+		t.Fatalf("%d files failed: %v", result.FailedFiles, result.Errors)
+	}
+	return result
+}
+
+func TestUploadFolderSkipsUnchangedFiles(t *testing.T) {
+	for _, sseC := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sse-c=%v", sseC), func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, map[string]string{"a.txt": "alpha", "sub/b.json": "{}"})
+			p := newBucketProvider(sseC)
+
+			result := push(t, p, dir)
+			if result.UploadedFiles != 2 || p.content["backup/a.txt"] == nil || p.content["backup/sub/b.json"] == nil {
+				t.Fatalf("first push uploaded %v", p.uploads)
+			}
+
+			result = push(t, p, dir)
+			if result.UploadedFiles != 0 || result.UnchangedFiles != 2 {
+				t.Errorf("second push uploaded %v", p.uploads)
+			}
+
+			// Same size, different content
+			writeTree(t, dir, map[string]string{"a.txt": "ALPHA"})
+			result = push(t, p, dir)
+			if result.UploadedFiles != 1 || string(p.content["backup/a.txt"]) != "ALPHA" {
+				t.Errorf("push after a change uploaded %v", p.uploads)
+			}
+		})
+	}
+}
+
+func TestUploadFolderWithoutStoredChecksum(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "alpha"})
+
+	// An SSE-C object uploaded by another tool has nothing to compare with, so it is uploaded again
+	p := newBucketProvider(true)
+	p.UploadObject(context.Background(), "backup/a.txt", strings.NewReader("alpha"), providers.UploadOptions{})
+
+	if result := push(t, p, dir); result.UploadedFiles != 1 {
+		t.Errorf("uploaded %v, want the file uploaded again", p.uploads)
+	}
+	if result := push(t, p, dir); result.UploadedFiles != 0 {
+		t.Errorf("uploaded %v once the checksum was stored", p.uploads)
+	}
+}
+
+func TestUploadFolderHeadsOnlyOnETagMismatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.txt": "alpha", "b.txt": "bravo"})
+
+	p := newBucketProvider(false)
+	push(t, p, dir)
+	push(t, p, dir)
+	if p.heads != 0 {
+		t.Errorf("made %d HEAD requests for objects whose ETag matched", p.heads)
+	}
+}
+
+func TestUploadFolderMissingDirectory(t *testing.T) {
+	p := newBucketProvider(false)
+	_, err := NewUploader(p, Options{}).UploadFolder(context.Background(), filepath.Join(t.TempDir(), "missing"), "backup")
+	if err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
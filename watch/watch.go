@@ -0,0 +1,260 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/providers"
+	"download-file-from-bucket/tracing"
+)
+
+// State records what has been downloaded so far, so a restarted watcher only fetches what is new.
+// Objects are compared by ETag rather than by modification time: an upload that takes long to
+// complete can appear in a listing after objects modified later than it.
+type State struct {
+	// Objects maps each downloaded or filtered key to the ETag it had. Keys that are no
+	// longer listed are forgotten, so it holds no more than the source does.
+	Objects map[string]string `json:"objects"`
+
+	// dirty is set when Objects changed since the state was loaded or saved
+	dirty bool
+}
+
+// LoadState reads a state file; a missing file is an empty state
+func LoadState(filename string) (*State, error) {
+	state := &State{Objects: make(map[string]string)}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse watch state %s: %w", filename, err)
+	}
+	if state.Objects == nil {
+		state.Objects = make(map[string]string)
+	}
+
+	return state, nil
+}
+
+// Save writes the state file, replacing it atomically so a crash never leaves it half written
+func (s *State) Save(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watch state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+
+	s.dirty = false
+	return nil
+}
+
+// Changed returns the objects that are new or whose ETag differs from the one downloaded
+func (s *State) Changed(objects []providers.Object) []providers.Object {
+	var changed []providers.Object
+	for _, obj := range objects {
+		if etag, seen := s.Objects[obj.Key]; !seen || etag != obj.ETag {
+			changed = append(changed, obj)
+		}
+	}
+	return changed
+}
+
+// Record marks an object as handled, either downloaded or left out by the filters
+func (s *State) Record(obj providers.Object) {
+	if etag, ok := s.Objects[obj.Key]; !ok || etag != obj.ETag {
+		s.Objects[obj.Key] = obj.ETag
+		s.dirty = true
+	}
+}
+
+// Retain forgets the keys that are not among the listed objects, which have been deleted
+func (s *State) Retain(objects []providers.Object) {
+	listed := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		listed[obj.Key] = true
+	}
+	for key := range s.Objects {
+		if !listed[key] {
+			delete(s.Objects, key)
+			s.dirty = true
+		}
+	}
+}
+
+// EventType describes what happened to an object
+type EventType string
+
+const (
+	// EventDownloaded means a new object was downloaded
+	EventDownloaded EventType = "downloaded"
+	// EventUpdated means an object that changed since it was last downloaded was downloaded again
+	EventUpdated EventType = "updated"
+	// EventFailed means downloading an object failed; it is retried on the next poll
+	EventFailed EventType = "failed"
+)
+
+// Event reports a download made by the watcher
+type Event struct {
+	Time  time.Time `json:"time"`
+	Type  EventType `json:"type"`
+	Key   string    `json:"key"`
+	ETag  string    `json:"etag,omitempty"`
+	Size  int64     `json:"size"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Watcher polls a source and downloads objects that are new or changed
+type Watcher struct {
+	downloader *downloader.Downloader
+	source     downloader.Source
+	sink       downloader.Sink
+	stateFile  string
+	state      *State
+	onEvent    func(Event)
+}
+
+// NewWatcher creates a watcher that downloads from source into sink, keeping its state in stateFile.
+// onEvent, if set, is called for every object downloaded or failed.
+func NewWatcher(dl *downloader.Downloader, source downloader.Source, sink downloader.Sink, stateFile string, onEvent func(Event)) (*Watcher, error) {
+	state, err := LoadState(stateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{
+		downloader: dl,
+		source:     source,
+		sink:       sink,
+		stateFile:  stateFile,
+		state:      state,
+		onEvent:    onEvent,
+	}, nil
+}
+
+// Run polls every interval until ctx is cancelled. onPoll, if set, is called with the result of
+// every poll that found something to download. Errors from a poll are passed to onPoll and do
+// not stop the watcher; only failing to save the state does.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration, onPoll func(*downloader.DownloadResult, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := w.Poll(ctx)
+
+		// Whatever was downloaded before a cancellation is still recorded.
+		// Polls that found nothing new leave the file alone.
+		if w.state.dirty {
+			if err := w.state.Save(w.stateFile); err != nil {
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if onPoll != nil && (err != nil || result.TotalFiles > 0) {
+			onPoll(result, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll lists the source once and downloads the objects that are new or changed
+func (w *Watcher) Poll(ctx context.Context) (result *downloader.DownloadResult, err error) {
+	// Each poll is a trace of its own
+	ctx, span := tracing.Start(ctx, "poll", attribute.String("prefix", w.source.Prefix))
+	defer func() { tracing.End(span, err) }()
+
+	listPrefix := w.source.Prefix + w.source.Pattern.LiteralPrefix()
+	listCtx, listSpan := tracing.Start(ctx, "list", attribute.String("prefix", listPrefix))
+	objects, err := w.source.Provider.ListObjects(listCtx, listPrefix)
+	listSpan.SetAttributes(attribute.Int("objects", len(objects)))
+	tracing.End(listSpan, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	w.state.Retain(objects)
+	changed := w.state.Changed(objects)
+	span.SetAttributes(attribute.Int("changed", len(changed)))
+	byKey := make(map[string]providers.Object, len(changed))
+	for _, obj := range changed {
+		byKey[obj.Key] = obj
+	}
+
+	// Progress is reported from a single goroutine, so the state needs no locking
+	result, err = w.downloader.DownloadSourceObjects(ctx, w.source, changed, w.sink, func(progress providers.DownloadProgress) {
+		obj := byKey[progress.Key]
+		event := Event{
+			Time: time.Now().UTC(),
+			Key:  obj.Key,
+			ETag: obj.ETag,
+			Size: obj.Size,
+		}
+
+		switch {
+		case progress.Error != nil:
+			event.Type = EventFailed
+			event.Error = progress.Error.Error()
+		case w.state.Objects[obj.Key] != "":
+			event.Type = EventUpdated
+			w.state.Record(obj)
+		default:
+			event.Type = EventDownloaded
+			w.state.Record(obj)
+		}
+
+		if w.onEvent != nil {
+			w.onEvent(event)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Objects the filters left out stay out until they change, so they are not checked again
+	for _, obj := range result.Filtered {
+		w.state.Record(obj)
+	}
+
+	return result, nil
+}
+
+// State returns the current state of the watcher
+func (w *Watcher) State() *State {
+	return w.state
+}
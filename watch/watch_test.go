@@ -0,0 +1,155 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"download-file-from-bucket/downloader"
+	"download-file-from-bucket/providers"
+)
+
+// taggedProvider serves objects with tags and counts the tag requests
+type taggedProvider struct {
+	providers.Provider
+
+	mu          sync.Mutex
+	objects     map[string]providers.Object
+	tags        map[string]map[string]string
+	tagRequests int
+}
+
+func (p *taggedProvider) ListObjects(ctx context.Context, prefix string) ([]providers.Object, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var objects []providers.Object
+	for _, obj := range p.objects {
+		objects = append(objects, obj)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (p *taggedProvider) DownloadObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader([]byte(key))), nil
+}
+
+func (p *taggedProvider) GetObjectTags(ctx context.Context, key, versionID string) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tagRequests++
+	return p.tags[key], nil
+}
+
+func TestPollRecordsFilteredObjects(t *testing.T) {
+	provider := &taggedProvider{
+		objects: map[string]providers.Object{
+			"in/a": {Key: "in/a", ETag: "1"},
+			"in/b": {Key: "in/b", ETag: "1"},
+		},
+		tags: map[string]map[string]string{
+			"in/a": {"env": "prod"},
+			"in/b": {"env": "dev"},
+		},
+	}
+
+	dir := t.TempDir()
+	dl := downloader.NewDownloader(provider, downloader.Options{Filter: downloader.Filter{Tags: map[string]string{"env": "prod"}}})
+	var events []Event
+	w, err := NewWatcher(dl, downloader.Source{Provider: provider, Prefix: "in/"}, downloader.NewDirSink(dir), filepath.Join(dir, "state.json"), func(e Event) {
+		events = append(events, e)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := w.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Key != "in/a" || events[0].Type != EventDownloaded {
+		t.Fatalf("events = %+v, want in/a downloaded", events)
+	}
+	if provider.tagRequests != 2 {
+		t.Fatalf("made %d tag requests, want 2", provider.tagRequests)
+	}
+
+	// Nothing changed, so the filtered object is not checked again
+	if _, err := w.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if provider.tagRequests != 2 {
+		t.Errorf("second poll made %d more tag requests, want none", provider.tagRequests-2)
+	}
+
+	// A new ETag means the object is checked again
+	provider.objects["in/b"] = providers.Object{Key: "in/b", ETag: "2"}
+	provider.tags["in/b"] = map[string]string{"env": "prod"}
+	if _, err := w.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if provider.tagRequests != 3 || len(events) != 2 || events[1].Key != "in/b" {
+		t.Errorf("after b changed: %d tag requests, events %+v", provider.tagRequests, events)
+	}
+
+	// Deleted objects are forgotten
+	delete(provider.objects, "in/a")
+	if _, err := w.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.State().Objects["in/a"]; ok || len(w.State().Objects) != 1 {
+		t.Errorf("state = %v, want only in/b", w.State().Objects)
+	}
+}
+
+func TestStateIsDirtyOnlyWhenChanged(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+	state, err := LoadState(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects := []providers.Object{{Key: "a", ETag: "1"}, {Key: "b", ETag: "1"}}
+	state.Retain(objects)
+	if state.dirty {
+		t.Error("retaining every key of an empty state should change nothing")
+	}
+
+	state.Record(objects[0])
+	if !state.dirty {
+		t.Fatal("recording a new object should change the state")
+	}
+	if err := state.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	state.Record(objects[0])
+	if state.dirty {
+		t.Error("recording the same ETag again should change nothing")
+	}
+	state.Record(providers.Object{Key: "a", ETag: "2"})
+	if !state.dirty {
+		t.Error("recording a new ETag should change the state")
+	}
+	if err := state.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	state.Retain(objects[1:])
+	if !state.dirty || len(state.Objects) != 0 {
+		t.Errorf("retaining only b should forget a: %v", state.Objects)
+	}
+
+	loaded, err := LoadState(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Objects["a"] != "2" || loaded.dirty {
+		t.Errorf("loaded state = %+v", loaded)
+	}
+}